@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"ezrpro.com/micro/kit/pkg/cst"
+	"ezrpro.com/micro/kit/pkg/generator/assignment"
 	"ezrpro.com/micro/kit/pkg/generator/service"
 	"ezrpro.com/micro/kit/pkg/utils"
 	"github.com/sirupsen/logrus"
@@ -30,6 +31,23 @@ var allCmd = &cobra.Command{
 			genFuncs []GenerateFunc
 			err      error
 		)
+
+		// 生成的transport和server需要保持一致
+		transportType := viper.GetString("g_a_transport_type")
+		transportTypes, err := parseTransportTypes(transportType)
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+		viper.Set("g_t_transport_type", transportType)
+		viper.Set("g_s_transport_type", transportType)
+		viper.Set("g_t_match_strategy", viper.GetString("g_a_match_strategy"))
+		viper.Set("g_t_enum_unknown", viper.GetString("g_a_enum_unknown"))
+		viper.Set("g_t_strict", viper.GetBool("g_a_strict"))
+		viper.Set("g_t_test", viper.GetBool("g_a_test"))
+		viper.Set("g_t_unchecked_narrowing", viper.GetBool("g_a_unchecked_narrowing"))
+		viper.Set("g_p_proto_path", viper.GetStringSlice("g_a_proto_path"))
+
 		// 如果使用的接口定义是proto生成的pb.go,则先分析pb.go
 		// 找出service和方法定义，通过该信息生成service.go
 		// 再向下生成其他组件
@@ -87,19 +105,29 @@ var allCmd = &cobra.Command{
 			}
 			genFuncs = []GenerateFunc{
 				generateEndpoint,
+			}
+			if transportTypes["thrift"] {
+				genFuncs = append(genFuncs, generateThrift)
+			}
+			genFuncs = append(genFuncs,
 				tg.generateTransport,
 				generateServer,
 				generateClient,
-			}
+			)
 		} else {
 			tg := &TransportGenerator{}
 			genFuncs = []GenerateFunc{
 				generateProtobuf,
 				generateEndpoint,
+			}
+			if transportTypes["thrift"] {
+				genFuncs = append(genFuncs, generateThrift)
+			}
+			genFuncs = append(genFuncs,
 				tg.generateTransport,
 				generateServer,
 				generateClient,
-			}
+			)
 		}
 
 		for _, genFunc := range genFuncs {
@@ -118,4 +146,25 @@ func init() {
 	allCmd.Flags().StringP("pkg", "p", "", "If you want to replace package of source file ")
 	viper.BindPFlag("g_a_package", allCmd.Flags().Lookup("pkg"))
 	viper.BindPFlag("g_a_source_file", allCmd.Flags().Lookup("source"))
+
+	allCmd.Flags().StringP("transport", "t", "grpc,http", "Transport types separated by comma(all, grpc, thrift, http)")
+	viper.BindPFlag("g_a_transport_type", allCmd.Flags().Lookup("transport"))
+
+	allCmd.Flags().Bool("test", false, "Generate round trip tests(convert_test.go) of gRPC conversions")
+	viper.BindPFlag("g_a_test", allCmd.Flags().Lookup("test"))
+
+	allCmd.Flags().StringP("match", "m", string(assignment.DefaultMatchStrategy), "Field match strategy of conversion(exact, case_insensitive, initialism, tag)")
+	viper.BindPFlag("g_a_match_strategy", allCmd.Flags().Lookup("match"))
+
+	allCmd.Flags().String("enum-unknown", string(assignment.DefaultEnumUnknown), "Behaviour of unknown enum value in conversion(default, passthrough, error)")
+	viper.BindPFlag("g_a_enum_unknown", allCmd.Flags().Lookup("enum-unknown"))
+
+	allCmd.Flags().Bool("strict", false, "Fail when any field or enum member of conversion has no mapping")
+	viper.BindPFlag("g_a_strict", allCmd.Flags().Lookup("strict"))
+
+	allCmd.Flags().Bool("unchecked-narrowing", false, "Convert numbers (integer narrowing, float to integer, float64 to float32) without range check, converters will not return error for overflow")
+	viper.BindPFlag("g_a_unchecked_narrowing", allCmd.Flags().Lookup("unchecked-narrowing"))
+
+	allCmd.Flags().StringSliceP("proto-path", "I", nil, "Additional import paths for protoc, e.g. the googleapis directory containing google/api/annotations.proto required by kit:http routes")
+	viper.BindPFlag("g_a_proto_path", allCmd.Flags().Lookup("proto-path"))
 }
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	"ezrpro.com/micro/kit/pkg/generator/assignment"
+	"ezrpro.com/micro/kit/pkg/generator/convert"
+	"ezrpro.com/micro/kit/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var convertCmd = &cobra.Command{
+	Use:     "convert",
+	Short:   "generate converters between any two structs",
+	Aliases: []string{"cv"},
+	Run: func(cmd *cobra.Command, args []string) {
+		from, to := viper.GetString("g_c_from"), viper.GetString("g_c_to")
+		if from == "" || to == "" {
+			logrus.Error("You must provide both source and target struct, e.g. --from ./pkg/model.User --to ./pkg/addservice.User")
+			return
+		}
+
+		err := generateConvert(from, to)
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+	},
+}
+
+// 结构体的引用 包路径.结构体名
+type structRef struct {
+	dir        string // 包所在的文件夹
+	file       string // 定义结构体的文件
+	importPath string
+	name       string
+}
+
+// 解析结构体的引用，包路径可以是相对当前目录的路径，也可以是import路径
+// e.g. ./pkg/model.User  ezrpro.com/micro/demo/pkg/model.User
+func parseStructRef(s string) (*structRef, error) {
+	idx := strings.LastIndex(s, ".")
+	if idx <= 0 || idx < strings.LastIndex(s, "/") || idx == len(s)-1 {
+		return nil, fmt.Errorf("Invalid struct(%s), must be <package path>.<struct name>", s)
+	}
+
+	ref := &structRef{name: s[idx+1:]}
+	dir := s[:idx]
+	switch {
+	case filepath.IsAbs(dir):
+		ref.dir = dir
+	case dir == "." || strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../"):
+		ref.dir = filepath.Join(utils.GetPWD(), dir)
+	default:
+		ref.dir = filepath.Join(utils.GetGoSrc(), dir)
+	}
+
+	file, err := findStructFile(ref.dir, ref.name)
+	if err != nil {
+		return nil, err
+	}
+	ref.file = file
+	ref.importPath = utils.GetImportPathByFileAbsPath(file)
+	return ref, nil
+}
+
+// 在包中查找定义结构体的文件
+func findStructFile(dir, name string) (string, error) {
+	fileinfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, fileinfo := range fileinfos {
+		if fileinfo.IsDir() ||
+			!strings.HasSuffix(fileinfo.Name(), ".go") ||
+			strings.HasSuffix(fileinfo.Name(), "_test.go") {
+			continue
+		}
+
+		filename := filepath.Join(dir, fileinfo.Name())
+		f, err := parser.ParseFile(token.NewFileSet(), filename, nil, 0)
+		if err != nil {
+			return "", err
+		}
+		if obj := f.Scope.Lookup(name); obj != nil && obj.Kind == ast.Typ {
+			return filename, nil
+		}
+	}
+	return "", fmt.Errorf("Not found struct(%s) in %s", name, dir)
+}
+
+func generateConvert(from, to string) error {
+	matchStrategy, err := assignment.ParseMatchStrategy(viper.GetString("g_c_match_strategy"))
+	if err != nil {
+		return err
+	}
+
+	enumUnknown, err := assignment.ParseEnumUnknownBehaviour(viper.GetString("g_c_enum_unknown"))
+	if err != nil {
+		return err
+	}
+
+	typeConverters, err := getTypeConverters()
+	if err != nil {
+		return err
+	}
+
+	src, err := parseStructRef(from)
+	if err != nil {
+		return err
+	}
+
+	dst, err := parseStructRef(to)
+	if err != nil {
+		return err
+	}
+
+	// 结构体字段引用的类型可能定义在包中的其他文件
+	srcTree, err := cst.NewPackage(src.dir)
+	if err != nil {
+		return err
+	}
+
+	dstTree, err := cst.NewPackage(dst.dir)
+	if err != nil {
+		return err
+	}
+
+	// 目标结构体由protoc生成时，optional字段通过getter取值及proto.Int64等辅助方法赋值
+	schema := assignment.SchemaPlain
+	if strings.HasSuffix(dst.file, ".pb.go") {
+		schema = assignment.SchemaProtobuf
+	}
+
+	// 默认输出到./pkg/convert/user_to_addservice_user.go
+	filename := viper.GetString("g_c_output")
+	if filename == "" {
+		filename = filepath.Join(
+			utils.GetPWD(),
+			"pkg",
+			convert.DefaultPackageName,
+			fmt.Sprintf("%s_to_%s_%s.go",
+				utils.ToLowerSnakeCase(src.name),
+				dstTree.PackageName(),
+				utils.ToLowerSnakeCase(dst.name),
+			),
+		)
+	}
+
+	file, err := createFile(filename)
+	if err != nil {
+		return errors.New("Create file " + filename + " error:" + err.Error())
+	}
+	defer GoimportsAndformat(filename)
+	defer file.Close()
+
+	gen := convert.NewConvertGenerator(
+		srcTree,
+		dstTree,
+		src.name,
+		dst.name,
+		convert.WithWriter(file),
+		convert.WithPackageName(filepath.Base(filepath.Dir(filename))),
+		convert.WithImports(src.importPath, dst.importPath),
+		convert.WithBoth(viper.GetBool("g_c_both")),
+		convert.WithMatchStrategy(matchStrategy),
+		convert.WithEnumUnknown(enumUnknown),
+		convert.WithTypeConverters(typeConverters),
+		convert.WithStrict(viper.GetBool("g_c_strict")),
+		convert.WithSchema(schema),
+		convert.WithUncheckedNarrowing(viper.GetBool("g_c_unchecked_narrowing")),
+	)
+
+	return gen.Generate()
+}
+
+func init() {
+	generateCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().String("from", "", "Source struct, <package path>.<struct name>")
+	viper.BindPFlag("g_c_from", convertCmd.Flags().Lookup("from"))
+
+	convertCmd.Flags().String("to", "", "Target struct, <package path>.<struct name>")
+	viper.BindPFlag("g_c_to", convertCmd.Flags().Lookup("to"))
+
+	convertCmd.Flags().StringP("output", "o", "", "Output file, default is ./pkg/convert/<from>_to_<package>_<to>.go")
+	viper.BindPFlag("g_c_output", convertCmd.Flags().Lookup("output"))
+
+	convertCmd.Flags().Bool("both", false, "Also generate the converter from target struct to source struct")
+	viper.BindPFlag("g_c_both", convertCmd.Flags().Lookup("both"))
+
+	convertCmd.Flags().StringP("match", "m", string(assignment.DefaultMatchStrategy), "Field match strategy of conversion(exact, case_insensitive, initialism, tag)")
+	viper.BindPFlag("g_c_match_strategy", convertCmd.Flags().Lookup("match"))
+
+	convertCmd.Flags().String("enum-unknown", string(assignment.DefaultEnumUnknown), "Behaviour of unknown enum value in conversion(default, passthrough, error)")
+	viper.BindPFlag("g_c_enum_unknown", convertCmd.Flags().Lookup("enum-unknown"))
+
+	convertCmd.Flags().Bool("strict", false, "Fail when any field or enum member of conversion has no mapping")
+	viper.BindPFlag("g_c_strict", convertCmd.Flags().Lookup("strict"))
+	convertCmd.Flags().Bool("unchecked-narrowing", false, "Convert numbers (integer narrowing, float to integer, float64 to float32) without range check, converters will not return error for overflow")
+	viper.BindPFlag("g_c_unchecked_narrowing", convertCmd.Flags().Lookup("unchecked-narrowing"))
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"ezrpro.com/micro/kit/pkg/cst"
+	gen "ezrpro.com/micro/kit/pkg/generator"
 	"ezrpro.com/micro/kit/pkg/generator/endpoint"
 	"ezrpro.com/micro/kit/pkg/generator/service"
 	"ezrpro.com/micro/kit/pkg/utils"
@@ -42,6 +43,11 @@ func generateEndpoint(sourceFile string) error {
 
 	serviceSuffix := utils.SelectServiceSuffix(sourceFile)
 	baseServiceName := service.GetBaseServiceName(cst.PackageName(), serviceSuffix)
+	// instrumenting.go按service的错误码统计错误数
+	if err := ensureServiceErrors(baseServiceName); err != nil {
+		return err
+	}
+
 	endpointPath := utils.GetEndpointFilePath(baseServiceName)
 	endpointPackageName := filepath.Base(endpointPath)
 	var options = []endpoint.Option{
@@ -49,8 +55,36 @@ func generateEndpoint(sourceFile string) error {
 		endpoint.WithEndpointPackageName(endpointPackageName),
 		endpoint.WithServiceSuffix(serviceSuffix),
 	}
+	serviceIface, err := gen.FilterInterface(cst.Interfaces(), serviceSuffix)
+	if err != nil {
+		return err
+	}
+	envelopes, err := gen.NewEnvelopes(cst.PackageName(), serviceIface)
+	if err != nil {
+		return err
+	}
+
+	validations, err := gen.NewValidations(cst)
+	if err != nil {
+		return err
+	}
+
 	for templateName, template := range endpoint.TemplateMap {
 		filename := filepath.Join(endpointPath, fmt.Sprintf("%s.go", templateName.String()))
+		// Validate方法输出到service所在的包中，没有声明校验规则时不需要生成
+		if templateName == endpoint.ValidateTemplate {
+			if len(validations) == 0 {
+				continue
+			}
+			filename = filepath.Join(filepath.Dir(sourceFile), fmt.Sprintf("%s.go", templateName.String()))
+		}
+		// 合成的请求及响应结构体输出到service所在的包中，所有方法都是标准签名时不需要生成
+		if templateName == endpoint.EnvelopeTemplate {
+			if !gen.HasSynthesizedEnvelope(envelopes) {
+				continue
+			}
+			filename = filepath.Join(filepath.Dir(sourceFile), fmt.Sprintf("%s.go", templateName.String()))
+		}
 
 		file, err := createFile(filename)
 		if err != nil {
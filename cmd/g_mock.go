@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	"ezrpro.com/micro/kit/pkg/generator/mock"
+	"ezrpro.com/micro/kit/pkg/generator/service"
+	"ezrpro.com/micro/kit/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var mockCmd = &cobra.Command{
+	Use:     "mock",
+	Short:   "generate programmable mock of service for tests",
+	Aliases: []string{"m"},
+	Run: func(cmd *cobra.Command, args []string) {
+		sourceFile := viper.GetString("g_m_source_file")
+		if sourceFile == "" {
+			logrus.Error("You must provide a source file for analyze of ast")
+			return
+		}
+
+		err := generateMock(sourceFile)
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+	},
+}
+
+func generateMock(sourceFile string) error {
+	cst, err := cst.New(sourceFile)
+	if err != nil {
+		return err
+	}
+	serviceSuffix := utils.SelectServiceSuffix(sourceFile)
+	baseServiceName := service.GetBaseServiceName(cst.PackageName(), serviceSuffix)
+	// 未设置XFunc的方法返回Unimplemented错误
+	if err := ensureServiceErrors(baseServiceName); err != nil {
+		return err
+	}
+
+	mockPath := utils.GetMockFilePath(baseServiceName)
+	mockPackageName := filepath.Base(mockPath)
+	var options = []mock.Option{
+		mock.WithBaseServiceName(baseServiceName),
+		mock.WithMockPackageName(mockPackageName),
+		mock.WithServiceSuffix(serviceSuffix),
+	}
+	for templateName, template := range mock.TemplateMap {
+		filename := filepath.Join(mockPath, fmt.Sprintf("%s.go", templateName.String()))
+
+		file, err := createFile(filename)
+		if err != nil {
+			return errors.New("Create file " + filename + " error:" + err.Error())
+		}
+		defer GoimportsAndformat(filename)
+		defer file.Close()
+
+		options = append(options,
+			mock.WithReadWriter(
+				templateName,
+				strings.NewReader(template),
+				file),
+		)
+	}
+
+	gen := mock.NewMockGenerator(
+		cst,
+		options...,
+	)
+
+	return gen.Generate()
+}
+
+func init() {
+	generateCmd.AddCommand(mockCmd)
+
+	mockCmd.Flags().StringP("source", "s", "", "Source file defined by the service interface")
+	viper.BindPFlag("g_m_source_file", mockCmd.Flags().Lookup("source"))
+}
@@ -33,6 +33,11 @@ var grpcCmd = &cobra.Command{
 }
 
 func generateProtobuf(sourceFile string) error {
+	typeConverters, err := getTypeConverters()
+	if err != nil {
+		return err
+	}
+
 	cst, err := cst.New(sourceFile)
 	if err != nil {
 		return err
@@ -56,6 +61,7 @@ func generateProtobuf(sourceFile string) error {
 		),
 		protobuf.WithStructFilter(generator.DefaultStructFilter),
 		protobuf.WithServiceSuffix(serviceSuffix),
+		protobuf.WithTypeConverters(typeConverters),
 	)
 
 	err = gen.Generate()
@@ -63,7 +69,7 @@ func generateProtobuf(sourceFile string) error {
 		return err
 	}
 
-	err = generateProtobufGo(filename)
+	err = generateProtobufGo(filename, viper.GetStringSlice("g_p_proto_path"))
 	if err != nil {
 		return err
 	}
@@ -86,4 +92,6 @@ func init() {
 
 	grpcCmd.Flags().StringP("source", "s", "", "Source file defined by the service interface")
 	viper.BindPFlag("g_p_source_file", grpcCmd.Flags().Lookup("source"))
+	grpcCmd.Flags().StringSliceP("proto-path", "I", nil, "Additional import paths for protoc, e.g. the googleapis directory containing google/api/annotations.proto required by kit:http routes")
+	viper.BindPFlag("g_p_proto_path", grpcCmd.Flags().Lookup("proto-path"))
 }
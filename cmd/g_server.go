@@ -35,6 +35,11 @@ var serverCmd = &cobra.Command{
 }
 
 func generateServer(sourceFile string) error {
+	transportTypes, err := parseTransportTypes(viper.GetString("g_s_transport_type"))
+	if err != nil {
+		return err
+	}
+
 	cst, err := cst.New(sourceFile)
 	if err != nil {
 		return err
@@ -49,6 +54,9 @@ func generateServer(sourceFile string) error {
 		server.WithServerPackageName(serverPackageName),
 		server.WithServiceSuffix(serviceSuffix),
 	}
+	for transportType := range transportTypes {
+		options = append(options, server.WithTransportTypes(transportType))
+	}
 	for templateName, template := range server.TemplateMap {
 		filename := filepath.Join(serverPath, fmt.Sprintf("%s.go", templateName.String()))
 
@@ -86,4 +94,7 @@ func init() {
 
 	serverCmd.Flags().StringP("source", "s", "", "Source file defined by the service interface")
 	viper.BindPFlag("g_s_source_file", serverCmd.Flags().Lookup("source"))
+
+	serverCmd.Flags().StringP("transport", "t", "grpc,http", "Transport types separated by comma(all, grpc, thrift, http)")
+	viper.BindPFlag("g_s_transport_type", serverCmd.Flags().Lookup("transport"))
 }
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	"ezrpro.com/micro/kit/pkg/generator/service"
+	"ezrpro.com/micro/kit/pkg/generator/test"
+	"ezrpro.com/micro/kit/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var testCmd = &cobra.Command{
+	Use:     "test",
+	Short:   "generate in-process test client wiring service, endpoint and transport",
+	Aliases: []string{"te"},
+	Run: func(cmd *cobra.Command, args []string) {
+		sourceFile := viper.GetString("g_te_source_file")
+		if sourceFile == "" {
+			logrus.Error("You must provide a source file for analyze of ast")
+			return
+		}
+
+		err := generateTest(sourceFile)
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+	},
+}
+
+func generateTest(sourceFile string) error {
+	transportTypes, err := parseTransportTypes(viper.GetString("g_te_transport_type"))
+	if err != nil {
+		return err
+	}
+	// 测试客户端只支持grpc、http及thrift
+	if !transportTypes["grpc"] && !transportTypes["http"] && !transportTypes["thrift"] {
+		return errors.New("Test client requires grpc, http or thrift transport")
+	}
+
+	cst, err := cst.New(sourceFile)
+	if err != nil {
+		return err
+	}
+
+	serviceSuffix := utils.SelectServiceSuffix(sourceFile)
+	baseServiceName := service.GetBaseServiceName(cst.PackageName(), serviceSuffix)
+	testPath := utils.GetTestFilePath(baseServiceName)
+	testPackageName := filepath.Base(testPath)
+	var options = []test.Option{
+		test.WithBaseServiceName(baseServiceName),
+		test.WithTestPackageName(testPackageName),
+		test.WithServiceSuffix(serviceSuffix),
+	}
+	for transportType := range transportTypes {
+		if transportType == "grpc" || transportType == "http" || transportType == "thrift" {
+			options = append(options, test.WithTransportTypes(transportType))
+		}
+	}
+	for templateName, template := range test.TemplateMap {
+		filename := filepath.Join(testPath, fmt.Sprintf("%s.go", templateName.String()))
+
+		file, err := createFile(filename)
+		if err != nil {
+			return errors.New("Create file " + filename + " error:" + err.Error())
+		}
+		defer GoimportsAndformat(filename)
+		defer file.Close()
+
+		options = append(options,
+			test.WithReadWriter(
+				templateName,
+				strings.NewReader(template),
+				file),
+		)
+	}
+
+	gen := test.NewTestGenerator(
+		cst,
+		options...,
+	)
+
+	return gen.Generate()
+}
+
+func init() {
+	generateCmd.AddCommand(testCmd)
+
+	testCmd.Flags().StringP("source", "s", "", "Source file defined by the service interface")
+	viper.BindPFlag("g_te_source_file", testCmd.Flags().Lookup("source"))
+
+	testCmd.Flags().StringP("transport", "t", "grpc,http", "Transport types separated by comma(grpc, http, thrift)")
+	viper.BindPFlag("g_te_transport_type", testCmd.Flags().Lookup("transport"))
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	"ezrpro.com/micro/kit/pkg/generator"
+	"ezrpro.com/micro/kit/pkg/generator/service"
+	"ezrpro.com/micro/kit/pkg/generator/thrift"
+	"ezrpro.com/micro/kit/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var thriftCmd = &cobra.Command{
+	Use:     "thrift",
+	Short:   "generate thrift IDL of go-kit thrift",
+	Aliases: []string{"th"},
+	Run: func(cmd *cobra.Command, args []string) {
+		sourceFile := viper.GetString("g_th_source_file")
+		if sourceFile == "" {
+			logrus.Error("You must provide a source file for analyze of ast")
+			return
+		}
+
+		err := generateThrift(sourceFile)
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+	},
+}
+
+func generateThrift(sourceFile string) error {
+	cst, err := cst.New(sourceFile)
+	if err != nil {
+		return err
+	}
+	serviceSuffix := utils.SelectServiceSuffix(sourceFile)
+	baseServiceName := service.GetBaseServiceName(cst.PackageName(), serviceSuffix)
+	thriftPath := utils.GetThriftFilePath(baseServiceName)
+	filename := filepath.Join(thriftPath, cst.PackageName()+".thrift")
+
+	file, err := createFile(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gen := thrift.NewThriftGenerator(
+		cst,
+		thrift.WithWriter(file),
+		thrift.WithServiceNameNormalizer(
+			ServiceNameNormalizer{serviceSuffix: serviceSuffix},
+		),
+		thrift.WithStructFilter(generator.DefaultStructFilter),
+		thrift.WithServiceSuffix(serviceSuffix),
+	)
+
+	err = gen.Generate()
+	if err != nil {
+		return err
+	}
+
+	err = generateThriftGo(filename)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func init() {
+	generateCmd.AddCommand(thriftCmd)
+
+	thriftCmd.Flags().StringP("source", "s", "", "Source file defined by the service interface")
+	viper.BindPFlag("g_th_source_file", thriftCmd.Flags().Lookup("source"))
+}
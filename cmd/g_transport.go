@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
 
 	"ezrpro.com/micro/kit/pkg/cst"
+	"ezrpro.com/micro/kit/pkg/generator/assignment"
 	"ezrpro.com/micro/kit/pkg/generator/service"
 	"ezrpro.com/micro/kit/pkg/generator/transport"
 	"ezrpro.com/micro/kit/pkg/utils"
@@ -24,7 +26,6 @@ var transportCmd = &cobra.Command{
 	Short:   "generate source code of go-kit transport",
 	Aliases: []string{"t"},
 	Run: func(cmd *cobra.Command, args []string) {
-		//		transportType := viper.GetString("g_t_transport_type")
 		sourceFile := viper.GetString("g_t_source_file")
 		if sourceFile == "" {
 			logrus.Error("You must provide a source file for analyze of ast")
@@ -44,35 +45,107 @@ type TransportGenerator struct {
 	pbGoFilePath string
 }
 
+// 解析逗号分隔的transport类型 e.g. grpc,http  all
+func parseTransportTypes(s string) (map[string]bool, error) {
+	transportTypes := map[string]bool{}
+	for _, typ := range strings.Split(s, ",") {
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if typ == "" {
+			continue
+		}
+
+		if typ == "all" {
+			for _, t := range AllTransportTypes {
+				transportTypes[t] = true
+			}
+			continue
+		}
+
+		var found bool
+		for _, t := range AllTransportTypes {
+			if t == typ {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Unsupport transport type:%s, must be one of %v", typ, AllTransportTypes)
+		}
+		transportTypes[typ] = true
+	}
+
+	if len(transportTypes) == 0 {
+		return nil, errors.New("You must provide at least one transport type")
+	}
+	return transportTypes, nil
+}
+
 func (tg *TransportGenerator) generateTransport(sourceFile string) error {
+	transportTypes, err := parseTransportTypes(viper.GetString("g_t_transport_type"))
+	if err != nil {
+		return err
+	}
+
+	matchStrategy, err := assignment.ParseMatchStrategy(viper.GetString("g_t_match_strategy"))
+	if err != nil {
+		return err
+	}
+
+	enumUnknown, err := assignment.ParseEnumUnknownBehaviour(viper.GetString("g_t_enum_unknown"))
+	if err != nil {
+		return err
+	}
+
+	typeConverters, err := getTypeConverters()
+	if err != nil {
+		return err
+	}
+
 	csTree, err := cst.New(sourceFile)
 	if err != nil {
 		return err
 	}
 	serviceSuffix := utils.SelectServiceSuffix(sourceFile)
 	baseServiceName := service.GetBaseServiceName(csTree.PackageName(), serviceSuffix)
+	if err := ensureServiceErrors(baseServiceName); err != nil {
+		return err
+	}
+
 	transportPath := utils.GetTransportFilePath(baseServiceName)
 	transportPackageName := filepath.Base(transportPath)
 	var options = []transport.Option{
 		transport.WithBaseServiceName(baseServiceName),
 		transport.WithTransportPackageName(transportPackageName),
 		transport.WithServiceSuffix(serviceSuffix),
+		transport.WithMatchStrategy(matchStrategy),
+		transport.WithEnumUnknown(enumUnknown),
+		transport.WithStrict(viper.GetBool("g_t_strict")),
+		transport.WithTypeConverters(typeConverters),
+		transport.WithUncheckedNarrowing(viper.GetBool("g_t_unchecked_narrowing")),
 	}
+	// 生成成功后才创建文件，生成失败(如严格模式下存在未赋值的字段)时不改动已有的文件
+	outputs := map[string]*bytes.Buffer{}
 	for templateName, template := range transport.TemplateMap {
-		filename := filepath.Join(transportPath, fmt.Sprintf("%s.go", templateName.String()))
-
-		file, err := createFile(filename)
-		if err != nil {
-			return errors.New("Create file " + filename + " error:" + err.Error())
+		// 往返测试使用grpc的decode/encode方法，需要同时生成grpc
+		if templateName == transport.ConvertTestTemplate {
+			if !viper.GetBool("g_t_test") || !transportTypes["grpc"] {
+				continue
+			}
+		} else if templateName != transport.OptionsTempalte &&
+			templateName != transport.ConvertTemplate &&
+			!transportTypes[templateName.String()] {
+			continue
 		}
-		defer GoimportsAndformat(filename)
-		defer file.Close()
+
+		filename := filepath.Join(transportPath, fmt.Sprintf("%s.go", templateName.String()))
+		output := &bytes.Buffer{}
+		outputs[filename] = output
 
 		options = append(options,
 			transport.WithReadWriter(
 				templateName,
 				strings.NewReader(template),
-				file),
+				output),
 		)
 	}
 
@@ -86,6 +159,19 @@ func (tg *TransportGenerator) generateTransport(sourceFile string) error {
 		return err
 	}
 
+	for filename, output := range outputs {
+		file, err := createFile(filename)
+		if err != nil {
+			return errors.New("Create file " + filename + " error:" + err.Error())
+		}
+		defer GoimportsAndformat(filename)
+		defer file.Close()
+
+		if _, err := output.WriteTo(file); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -95,6 +181,20 @@ func init() {
 	transportCmd.Flags().StringP("source", "s", "", "Source file defined by the service interface")
 	viper.BindPFlag("g_t_source_file", transportCmd.Flags().Lookup("source"))
 
-	transportCmd.Flags().StringP("transport", "t", "grpc", "Transport type(all, grpc, thrift, http)")
+	transportCmd.Flags().StringP("transport", "t", "grpc,http", "Transport types separated by comma(all, grpc, thrift, http)")
 	viper.BindPFlag("g_t_transport_type", transportCmd.Flags().Lookup("transport"))
+
+	transportCmd.Flags().Bool("test", false, "Generate round trip tests(convert_test.go) of gRPC conversions")
+	viper.BindPFlag("g_t_test", transportCmd.Flags().Lookup("test"))
+
+	transportCmd.Flags().StringP("match", "m", string(assignment.DefaultMatchStrategy), "Field match strategy of conversion(exact, case_insensitive, initialism, tag)")
+	viper.BindPFlag("g_t_match_strategy", transportCmd.Flags().Lookup("match"))
+
+	transportCmd.Flags().String("enum-unknown", string(assignment.DefaultEnumUnknown), "Behaviour of unknown enum value in conversion(default, passthrough, error)")
+	viper.BindPFlag("g_t_enum_unknown", transportCmd.Flags().Lookup("enum-unknown"))
+
+	transportCmd.Flags().Bool("strict", false, "Fail when any field or enum member of conversion has no mapping")
+	viper.BindPFlag("g_t_strict", transportCmd.Flags().Lookup("strict"))
+	transportCmd.Flags().Bool("unchecked-narrowing", false, "Convert numbers (integer narrowing, float to integer, float64 to float32) without range check, converters will not return error for overflow")
+	viper.BindPFlag("g_t_unchecked_narrowing", transportCmd.Flags().Lookup("unchecked-narrowing"))
 }
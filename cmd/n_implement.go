@@ -8,6 +8,7 @@ import (
 	"ezrpro.com/micro/kit/pkg/cst"
 	"ezrpro.com/micro/kit/pkg/generator/implement"
 	"ezrpro.com/micro/kit/pkg/generator/service"
+	"ezrpro.com/micro/kit/pkg/merge"
 	"ezrpro.com/micro/kit/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -54,7 +55,8 @@ func generateImpl(sourceFile string) error {
 	implPackageName := filepath.Base(implPath)
 	filename := filepath.Join(implPath, fmt.Sprintf("%s.go", baseServiceName))
 
-	file, err := createFile(filename)
+	// 接口的实现由用户填充，合并时只添加新的方法
+	file, err := createFile(filename, merge.WithKeepFuncs(true))
 	if err != nil {
 		return errors.New("Create file " + filename + " error:" + err.Error())
 	}
@@ -2,11 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"ezrpro.com/micro/kit/pkg/cst"
 	"ezrpro.com/micro/kit/pkg/generator/service"
+	"ezrpro.com/micro/kit/pkg/merge"
 	"ezrpro.com/micro/kit/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -49,7 +51,12 @@ func newService(serviceName string, methods []string, csTree cst.ConcreteSyntaxT
 
 	for templateName, template := range service.TemplateMap {
 		filename := filepath.Join(servicePath, fmt.Sprintf("%s.go", templateName.String()))
-		file, err := createFile(filename)
+		// basic service中的方法由用户实现，合并时只添加新的方法
+		// 没有语法树时生成的请求及响应结构体没有字段，合并时保留用户填充的字段
+		file, err := createFile(filename,
+			merge.WithKeepFuncs(templateName == service.BaseServiceTemplate),
+			merge.WithKeepFields(csTree == nil),
+		)
 		if err != nil {
 			logrus.Error(err)
 			return
@@ -75,6 +82,29 @@ func newService(serviceName string, methods []string, csTree cst.ConcreteSyntaxT
 	}
 }
 
+// endpoint及transport依赖service包中的错误类型，早于错误类型创建的service缺少errors.go时补充生成
+func ensureServiceErrors(serviceName string) error {
+	filename := filepath.Join(
+		utils.GetServiceFilePath(serviceName),
+		fmt.Sprintf("%s.go", service.ErrorsTemplate.String()),
+	)
+	if _, err := os.Stat(filename); err == nil {
+		return nil
+	}
+
+	file, err := createFile(filename)
+	if err != nil {
+		return err
+	}
+	defer GoimportsAndformat(filename)
+	defer file.Close()
+
+	return service.NewServiceGenerator(
+		service.WithServiceName(serviceName),
+		service.WithReadWriter(service.ErrorsTemplate, nil, file),
+	).Generate()
+}
+
 func init() {
 	newCmd.AddCommand(serviceCmd)
 
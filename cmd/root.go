@@ -27,6 +27,37 @@ func init() {
 	viper.BindPFlag("gk_folder", rootCmd.PersistentFlags().Lookup("folder"))
 	viper.BindPFlag("gk_force", rootCmd.PersistentFlags().Lookup("force"))
 	viper.BindPFlag("gk_debug", rootCmd.PersistentFlags().Lookup("debug"))
+
+	rootCmd.PersistentFlags().Bool("merge", false, "Merge generated code into existing go files, only generator-owned declarations are updated.")
+	viper.BindPFlag("gk_merge", rootCmd.PersistentFlags().Lookup("merge"))
+
+	rootCmd.PersistentFlags().StringP("config", "c", "", "Config file (default is kit.yaml in the current folder or $HOME)")
+	viper.BindPFlag("gk_config", rootCmd.PersistentFlags().Lookup("config"))
+
+	cobra.OnInitialize(initConfig)
+}
+
+// 读取配置文件，未指定时依次查找当前目录和$HOME下的kit.(yaml|json|toml)
+// 配置文件不存在时使用默认配置
+func initConfig() {
+	if configFile := viper.GetString("gk_config"); configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.SetConfigName("kit")
+		viper.AddConfigPath(".")
+		if home, err := os.UserHomeDir(); err == nil {
+			viper.AddConfigPath(home)
+		}
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return
+		}
+		logrus.Error("Read config file error:", err)
+		os.Exit(1)
+	}
+	logrus.Debug("Using config file:", viper.ConfigFileUsed())
 }
 
 func Execute() {
@@ -10,13 +10,16 @@ import (
 	"path/filepath"
 	"strings"
 
+	gen "ezrpro.com/micro/kit/pkg/generator"
+	"ezrpro.com/micro/kit/pkg/merge"
 	"github.com/sirupsen/logrus"
 	"github.com/smallnest/rpcx/log"
 	"github.com/spf13/viper"
 	"golang.org/x/tools/imports"
 )
 
-func createFile(filename string) (writerCloser io.WriteCloser, err error) {
+// 合并模式下已经存在的go文件不会被覆盖，生成的代码在Close时合并到文件中
+func createFile(filename string, mergeOpts ...merge.Option) (writerCloser io.WriteCloser, err error) {
 	dir, _ := filepath.Split(filename)
 
 	err = os.MkdirAll(dir, os.ModePerm)
@@ -26,6 +29,10 @@ func createFile(filename string) (writerCloser io.WriteCloser, err error) {
 
 	_, err = os.Stat(filename)
 	if err == nil {
+		if viper.GetBool("gk_merge") && filepath.Ext(filename) == ".go" {
+			log.Info("merge file:", filename)
+			return &mergeFile{filename: filename, opts: mergeOpts}, nil
+		}
 		if viper.GetBool("gk_force") {
 			log.Info("remove file:", filename)
 			err = os.Remove(filename)
@@ -41,13 +48,44 @@ func createFile(filename string) (writerCloser io.WriteCloser, err error) {
 	return os.Create(filename)
 }
 
-func generateProtobufGo(protoPath string) error {
+// 缓存生成的代码，Close时与已经存在的文件合并
+type mergeFile struct {
+	bytes.Buffer
+	filename string
+	opts     []merge.Option
+}
+
+func (f *mergeFile) Close() error {
+	// 生成器通过gen.ExecuteTemplate在模板执行成功后才输出，没有输出说明生成失败，保留原文件
+	if f.Len() == 0 {
+		return nil
+	}
+
+	existing, err := ioutil.ReadFile(f.filename)
+	if err != nil {
+		return err
+	}
+
+	body, err := merge.Merge(f.filename, existing, f.Bytes(), f.opts...)
+	if err != nil {
+		// 合并失败时保留原文件
+		logrus.Error("merge file:", f.filename, " error:", err)
+		return err
+	}
+	return ioutil.WriteFile(f.filename, body, 0644)
+}
+
+// includes为额外的import路径 e.g. 提供google/api/annotations.proto的googleapis目录
+func generateProtobufGo(protoPath string, includes []string) error {
 	genPbPath, _ := filepath.Split(protoPath)
-	args := []string{
-		"-I", genPbPath,
-		protoPath,
-		"--go_out=plugins=grpc:" + genPbPath,
+	args := []string{"-I", genPbPath}
+	for _, include := range includes {
+		args = append(args, "-I", include)
 	}
+	args = append(args,
+		protoPath,
+		"--go_out=plugins=grpc:"+genPbPath,
+	)
 	//protoc -I ./ --go_out=plugins=grpc:./ ./test.proto
 	cmd := exec.Command("protoc", args...)
 	var out bytes.Buffer
@@ -57,7 +95,31 @@ func generateProtobufGo(protoPath string) error {
 	logrus.Info("protoc ", strings.Join(args, " "))
 	err := cmd.Run()
 	if err != nil {
-		return fmt.Errorf(fmt.Sprint(err) + ": " + stderr.String())
+		return fmt.Errorf("%v: %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+func generateThriftGo(thriftPath string) error {
+	genThriftPath, _ := filepath.Split(thriftPath)
+	// idl中的namespace go和所在目录同名，输出到上级目录即生成在idl所在目录
+	args := []string{
+		"-r",
+		"--gen", "go:skip_remote",
+		"-out", filepath.Dir(filepath.Clean(genThriftPath)),
+		thriftPath,
+	}
+	//thrift -r --gen go:skip_remote -out ./ ./test/test.thrift
+	cmd := exec.Command("thrift", args...)
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	logrus.Info("thrift ", strings.Join(args, " "))
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, stderr.String())
 	}
 
 	return nil
@@ -88,3 +150,18 @@ func goimports(filepath string) error {
 
 	return nil
 }
+
+// 配置文件中gk_type_converters声明的自定义类型转换
+func getTypeConverters() (gen.TypeConverters, error) {
+	var converters gen.TypeConverters
+	err := viper.UnmarshalKey("gk_type_converters", &converters)
+	if err != nil {
+		return nil, err
+	}
+
+	err = converters.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return converters, nil
+}
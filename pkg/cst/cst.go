@@ -2,8 +2,12 @@ package cst
 
 import (
 	"bytes"
+	"fmt"
+	"go/ast"
 	"go/parser"
 	"go/token"
+	"os"
+	"strings"
 )
 
 type ConcreteSyntaxTree interface {
@@ -30,10 +34,11 @@ type Interface struct {
 }
 
 type Method struct {
-	Name    string
-	Recv    []Field
-	Params  []Field
-	Results []Field
+	Name       string
+	Recv       []Field
+	Params     []Field
+	Results    []Field
+	Directives Directives // 接口方法注释中的指令
 }
 
 type Field struct {
@@ -46,10 +51,42 @@ type Field struct {
 func New(filename string, opts ...Option) (ConcreteSyntaxTree, error) {
 	fset := token.NewFileSet()
 
-	f, err := parser.ParseFile(fset, filename, nil, 0)
+	// 接口方法的注释中可能声明了指令
+	f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	t := NewConcreteSyntaxTree(
+		fset,
+		f,
+		opts...,
+	)
+	if err := t.Parse(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// 解析文件夹中的整个包(不包括测试文件)，结构体及枚举可以定义在包中的任意文件中
+func NewPackage(dir string, opts ...Option) (ConcreteSyntaxTree, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
 	if err != nil {
 		return nil, err
 	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("Found %d packages in %s, expected 1", len(pkgs), dir)
+	}
+
+	var f *ast.File
+	for _, pkg := range pkgs {
+		// 关联包中不同文件之间引用的标识符，引用其他包的标识符无法解析，忽略返回的错误
+		pkg, _ = ast.NewPackage(fset, pkg.Files, nil, nil)
+		f = ast.MergePackageFiles(pkg, ast.FilterImportDuplicates)
+	}
 
 	t := NewConcreteSyntaxTree(
 		fset,
@@ -126,6 +163,15 @@ type BaseType struct {
 	Name     string // go显示类型名称 int, interface{}, XXXStruct
 	GoType   GoType // basic, array, map, struct
 	Position token.Position
+	Nested   *Type // 数组或者map作为元素类型时的完整定义 e.g. [][]int中的[]int
+}
+
+// 还原BaseType的完整类型定义，嵌套的数组或者map带上其元素类型
+func (t BaseType) FullType() Type {
+	if t.Nested != nil {
+		return *t.Nested
+	}
+	return Type{BaseType: t}
 }
 
 func (t BaseType) String() string {
@@ -158,6 +204,17 @@ func (t BaseType) String() string {
 	return t.Name
 }
 
+// 数组或者map最内层的元素类型 e.g. map[string][]*Foo => *Foo
+func (t Type) InnermostType() BaseType {
+	switch {
+	case t.ElementType != nil:
+		return t.ElementType.FullType().InnermostType()
+	case t.ValueType != nil:
+		return t.ValueType.FullType().InnermostType()
+	}
+	return t.BaseType
+}
+
 func (t Type) String() string {
 	switch t.GoType {
 	case BasicType:
@@ -0,0 +1,124 @@
+package cst
+
+import (
+	"fmt"
+	"go/ast"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 方法注释中的指令前缀
+// e.g.
+// // kit:http GET /users/{id}
+// // kit:timeout 2s
+// // kit:idempotent
+const directivePrefix = "kit:"
+
+// 方法注释中声明的指令
+type Directives struct {
+	HTTPMethod string        // kit:http声明的请求方法 e.g. GET
+	HTTPPath   string        // kit:http声明的路径，{name}为路径参数 e.g. /users/{id}
+	Timeout    time.Duration // kit:timeout声明的超时时间 e.g. 2s
+	Idempotent bool          // kit:idempotent声明方法是幂等的，失败时可以安全的重试
+}
+
+var httpMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true,
+}
+
+// 路径中的参数必须占据整个路径段 e.g. /users/{id}/orders/{orderId}
+var pathParamRegexp = regexp.MustCompile(`^\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// 是否声明了HTTP路由
+func (d Directives) HasHTTP() bool {
+	return d.HTTPMethod != ""
+}
+
+// HTTP请求是否使用body传递请求结构体，GET及DELETE使用query参数
+func (d Directives) HTTPBody() bool {
+	return d.HTTPMethod != "GET" && d.HTTPMethod != "DELETE"
+}
+
+// 路径中的参数名 e.g. /users/{id} => [id]
+func (d Directives) HTTPPathParams() []string {
+	var params []string
+	for _, segment := range strings.Split(d.HTTPPath, "/") {
+		if m := pathParamRegexp.FindStringSubmatch(segment); m != nil {
+			params = append(params, m[1])
+		}
+	}
+	return params
+}
+
+func parseDirectives(doc *ast.CommentGroup) (Directives, error) {
+	var d Directives
+	if doc == nil {
+		return d, nil
+	}
+
+	for _, comment := range doc.List {
+		text := comment.Text
+		if strings.HasPrefix(text, "/*") {
+			text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		} else {
+			text = strings.TrimPrefix(text, "//")
+		}
+
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, directivePrefix) {
+				continue
+			}
+			if err := d.parse(line); err != nil {
+				return d, err
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *Directives) parse(line string) error {
+	fields := strings.Fields(strings.TrimPrefix(line, directivePrefix))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty directive %q", line)
+	}
+
+	name, args := fields[0], fields[1:]
+	switch name {
+	case "http":
+		if len(args) != 2 {
+			return fmt.Errorf("directive %q must be kit:http METHOD /path", line)
+		}
+		method, path := strings.ToUpper(args[0]), args[1]
+		if !httpMethods[method] {
+			return fmt.Errorf("directive %q has unsupported http method %s", line, args[0])
+		}
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("directive %q must have a path starting with /", line)
+		}
+		for _, segment := range strings.Split(path, "/") {
+			if strings.ContainsAny(segment, "{}") && !pathParamRegexp.MatchString(segment) {
+				return fmt.Errorf("directive %q has invalid path parameter %s", line, segment)
+			}
+		}
+		d.HTTPMethod, d.HTTPPath = method, path
+	case "timeout":
+		if len(args) != 1 {
+			return fmt.Errorf("directive %q must be kit:timeout DURATION", line)
+		}
+		timeout, err := time.ParseDuration(args[0])
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("directive %q has invalid duration %s", line, args[0])
+		}
+		d.Timeout = timeout
+	case "idempotent":
+		if len(args) != 0 {
+			return fmt.Errorf("directive %q takes no arguments", line)
+		}
+		d.Idempotent = true
+	default:
+		return fmt.Errorf("unknown directive %q", line)
+	}
+	return nil
+}
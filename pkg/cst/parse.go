@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/format"
 	"go/parser"
 	"go/token"
@@ -42,6 +43,18 @@ type concreteSyntaxTree struct {
 	// key: import path e.g. github.com/xxx/xxx
 	// val: struct{}
 	parsedReferencePackageMap map[string]struct{}
+
+	// 正在解析字段的struct，防止相互引用的结构体解析时死循环
+	// e.g. type A struct{B *B}  type B struct{A *A}
+	// key: structName val: struct{}
+	parsingStructMap map[string]struct{}
+
+	// 正在解析接口方法的参数及返回值，方法签名中其他包的struct会作为合成的请求及响应结构体的字段
+	// e.g. GetUser(ctx context.Context, id int64) (model.User, error)
+	parsingInterface bool
+
+	// 解析过程中的第一个错误 e.g. 方法注释中的指令不合法
+	err error
 }
 
 func NewConcreteSyntaxTree(fset *token.FileSet, file *ast.File, opts ...Option) ConcreteSyntaxTree {
@@ -65,6 +78,7 @@ func newConcreteSyntaxTree(fset *token.FileSet, file *ast.File, opts ...Option)
 		structMap:                 make(map[string]map[string]*Struct),
 		typeMap:                   make(map[string]Type),
 		parsedReferencePackageMap: make(map[string]struct{}),
+		parsingStructMap:          make(map[string]struct{}),
 	}
 
 	return cst
@@ -160,23 +174,33 @@ func (t *concreteSyntaxTree) Parse() error {
 			t.parseFuncDecl(gen)
 		}
 	}
+	if err == nil {
+		err = t.err
+	}
 	return err
 }
 
 func (t *concreteSyntaxTree) parseConst(specs []ast.Spec) {
+	// 常量组中省略类型和值的常量沿用上一个常量的类型
+	// e.g. const ( A PhoneType = iota; B; C )
+	var lastType ast.Expr
 	for _, sp := range specs {
 		vsp, ok := sp.(*ast.ValueSpec)
 		if !ok {
 			panic("Var spec is not ValueSpec type, odd, skipping")
 		}
 
+		if vsp.Type != nil || len(vsp.Values) > 0 {
+			lastType = vsp.Type
+		}
+
 		for i, ident := range vsp.Names {
 			v := Constant{
 				Name: ident.Name,
 			}
 
-			if vsp.Type != nil {
-				v.Type = t.getFieldType(vsp.Type, "")
+			if lastType != nil {
+				v.Type = t.getFieldType(lastType, "")
 			}
 
 			if len(vsp.Values) > 0 {
@@ -365,7 +389,9 @@ func (t *concreteSyntaxTree) parseTypeSpec(specs []ast.Spec) {
 			case *ast.ChanType:
 				// TODO type chanWriter chan string
 			default:
-				panic(fmt.Sprintf("Unknown TypeSpec(type:%T pos:%s) analysis", tt, t.fset.Position(tt.Pos())))
+				if t.err == nil {
+					t.err = fmt.Errorf("Unknown TypeSpec(type:%T pos:%s) analysis", tt, t.fset.Position(tt.Pos()))
+				}
 			}
 		}
 	}
@@ -398,6 +424,12 @@ func (t *concreteSyntaxTree) parseInterface(it *ast.InterfaceType, iterName stri
 	if it.Methods == nil {
 		return
 	}
+	// pb.go中的grpc接口引用了grpc包，只处理service接口
+	if strings.HasSuffix(iterName, utils.GetServiceSuffix()) {
+		t.parsingInterface = true
+		defer func() { t.parsingInterface = false }()
+	}
+
 	iter.Methods = make([]Method, len(it.Methods.List))
 	for i, method := range it.Methods.List {
 		if len(method.Names) > 0 {
@@ -408,6 +440,12 @@ func (t *concreteSyntaxTree) parseInterface(it *ast.InterfaceType, iterName stri
 			iter.Methods[i].Params = t.parseFields(funcType.Params, "")
 			iter.Methods[i].Results = t.parseFields(funcType.Results, "")
 		}
+
+		directives, err := parseDirectives(method.Doc)
+		if err != nil && t.err == nil {
+			t.err = fmt.Errorf("%s.%s: %v", iterName, iter.Methods[i].Name, err)
+		}
+		iter.Methods[i].Directives = directives
 	}
 	t.interfaces = append(t.interfaces, iter)
 }
@@ -513,14 +551,14 @@ func (t *concreteSyntaxTree) getFieldType(expr ast.Expr, structName string) Type
 		typ.X = st.X
 		typ.Name = "[]" + st.String()
 		typ.GoType = ArrayType
-		typ.ElementType = &st.BaseType
+		typ.ElementType = nestedBaseType(st)
 	case *ast.MapType:
 		keyType := t.getFieldType(ex.Key, structName)
 		valType := t.getFieldType(ex.Value, structName)
 		typ.Name = fmt.Sprintf("map[%s]%s", keyType.String(), valType.String())
 		typ.GoType = MapType
 		typ.KeyType = &keyType.BaseType
-		typ.ValueType = &valType.BaseType
+		typ.ValueType = nestedBaseType(valType)
 	case *ast.StructType:
 		// XXX_NoUnkeyedLiteral struct{} `json:"-"`
 		//fmt.Println("----", ex, t.fset.Position(ex.Pos()))
@@ -537,11 +575,26 @@ func (t *concreteSyntaxTree) getFieldType(expr ast.Expr, structName string) Type
 		// var c chan int
 		// TODO
 	default:
-		panic(fmt.Sprintf("Unknown Expr(type:%T pos:%s) analysis", ex, t.fset.Position(ex.Pos())))
+		// e.g. 泛型类型 Foo[int]
+		if t.err == nil {
+			t.err = fmt.Errorf("Unknown Expr(type:%T pos:%s) analysis", ex, t.fset.Position(ex.Pos()))
+		}
+		typ.GoType = CrossProtocolUnsupportType
 	}
 	return typ
 }
 
+// 元素类型本身是数组或者map时，保留完整的类型定义
+// e.g. [][]int, map[string][]*Foo, []map[string]int
+func nestedBaseType(t Type) *BaseType {
+	base := t.BaseType
+	if t.GoType == ArrayType || t.GoType == MapType {
+		nested := t
+		base.Nested = &nested
+	}
+	return &base
+}
+
 func (t *concreteSyntaxTree) getFieldTypeByIdent(ident *ast.Ident, pkg, structName string) Type {
 	var typ Type
 	typ.Name = ident.Name
@@ -570,8 +623,10 @@ func (t *concreteSyntaxTree) parseStruct(id *ast.Ident, X, structName string) {
 	if X != "" && X != t.packageName {
 		// 如果入口处的structName是 XXXRequest或者XXXResponse之类后缀的结构体
 		// 尝试解析嵌套的其他包中的struct,例如model.Foo,取model文件夹中搜索
+		// 接口方法签名中引用的context.Context等标准库类型不需要解析
 		if strings.HasSuffix(structName, utils.GetRequestSuffix()) ||
-			strings.HasSuffix(structName, utils.GetResponseSuffix()) {
+			strings.HasSuffix(structName, utils.GetResponseSuffix()) ||
+			(t.parsingInterface && X != "context") {
 			t.parseReferencePackage(X)
 		}
 		return
@@ -584,6 +639,12 @@ func (t *concreteSyntaxTree) parseStruct(id *ast.Ident, X, structName string) {
 		PackageName: pkg,
 	}
 
+	if _, found := t.parsingStructMap[s.Name]; found {
+		return
+	}
+	t.parsingStructMap[s.Name] = struct{}{}
+	defer delete(t.parsingStructMap, s.Name)
+
 	if id.Obj != nil && id.Obj.Decl != nil {
 		s.Position = t.fset.Position(id.Obj.Pos())
 		if typeSpec, ok := id.Obj.Decl.(*ast.TypeSpec); ok {
@@ -627,8 +688,9 @@ func (t *concreteSyntaxTree) parseReferencePackage(pkg string) {
 			return
 		}
 
-		for _, path := range []string{"GOPATH", "GOROOT"} {
-			gopath := os.Getenv(path)
+		// 只解析GOPATH中的包，标准库(GOROOT)中的类型 e.g. time.Time 不需要解析，
+		// 其中使用了cst无法分析的语法 e.g. 泛型
+		for _, gopath := range filepath.SplitList(os.Getenv("GOPATH")) {
 			filePath := filepath.Join(gopath, "src", strings.Trim(imp.Path, "\""))
 			fileinfos, err := ioutil.ReadDir(filePath)
 			if err != nil {
@@ -636,22 +698,30 @@ func (t *concreteSyntaxTree) parseReferencePackage(pkg string) {
 				continue
 			}
 			for _, fileinfo := range fileinfos {
-				if fileinfo.IsDir() || !strings.HasSuffix(fileinfo.Name(), ".go") {
+				if fileinfo.IsDir() ||
+					!strings.HasSuffix(fileinfo.Name(), ".go") ||
+					strings.HasSuffix(fileinfo.Name(), "_test.go") {
 					continue
 				}
+				// 跳过当前平台不参与编译的文件 e.g. xxx_windows.go, //go:build ignore
+				if match, err := build.Default.MatchFile(filePath, fileinfo.Name()); err != nil || !match {
+					continue
+				}
+
 				fset := token.NewFileSet()
 				f, err := parser.ParseFile(fset, filepath.Join(filePath, fileinfo.Name()), nil, 0)
-				if err != nil {
-					panic(err)
+				if err == nil {
+					t2 := newConcreteSyntaxTree(fset, f)
+					t2.parsedReferencePackageMap = t.parsedReferencePackageMap
+					err = t2.Parse()
+					t.mergeStructMap(t2)
 				}
-
-				t2 := newConcreteSyntaxTree(fset, f)
-				t2.parsedReferencePackageMap = t.parsedReferencePackageMap
-				err = t2.Parse()
 				if err != nil {
-					panic(err)
+					if t.err == nil {
+						t.err = fmt.Errorf("parse package %s: %v", strings.Trim(imp.Path, "\""), err)
+					}
+					return
 				}
-				t.mergeStructMap(t2)
 			}
 		}
 	}
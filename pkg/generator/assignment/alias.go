@@ -100,12 +100,7 @@ func (oa ObjectAlias) CheckNil() (statement string, isNeed bool) {
 					continue
 				}
 				// 获取到字段值存储的数据类型
-				fieldType := field.Type.BaseType
-				if field.Type.ElementType != nil {
-					fieldType = *field.Type.ElementType
-				} else if field.Type.ValueType != nil {
-					fieldType = *field.Type.ValueType
-				}
+				fieldType := field.Type.InnermostType()
 
 				// 如果时指针类型，拼接到条件列表里
 				if field.Type.Star {
@@ -113,7 +108,8 @@ func (oa ObjectAlias) CheckNil() (statement string, isNeed bool) {
 				}
 
 				// 引用到基础类型的指针*int,*string,*float...不需要再向下找引用的数据结构
-				if fieldType.GoType == cst.BasicType {
+				// 最后一级引用也不需要，其类型可能是自定义转换的类型 e.g. decimal.Decimal
+				if fieldType.GoType == cst.BasicType || i == len(aliases)-1 {
 					continue
 				} else {
 					// 通过packagename和字段的类型名从关联的strcutmap中查找对应的数据结构
@@ -12,12 +12,24 @@ import (
 )
 
 type GeneratorFactory struct {
-	cst   cst.ConcreteSyntaxTree
-	pbcst cst.ConcreteSyntaxTree
+	cst            cst.ConcreteSyntaxTree
+	pbcst          cst.ConcreteSyntaxTree
+	opts           Options
+	converters     map[string]*converter // key: 转换方法名
+	unmappedFields map[string]struct{}   // 没有找到赋值来源的目标字段
+	fallibility    map[string]bool       // 转换方法是否返回error，key: 转换方法名
+	probe          *probe                // 试生成转换方法时不为nil
 }
 
-func NewGeneratorFactory(cst cst.ConcreteSyntaxTree, pbcst cst.ConcreteSyntaxTree) *GeneratorFactory {
-	return &GeneratorFactory{cst: cst, pbcst: pbcst}
+func NewGeneratorFactory(cst cst.ConcreteSyntaxTree, pbcst cst.ConcreteSyntaxTree, opts ...Option) *GeneratorFactory {
+	return &GeneratorFactory{
+		cst:            cst,
+		pbcst:          pbcst,
+		opts:           newOptions(opts...),
+		converters:     map[string]*converter{},
+		unmappedFields: map[string]struct{}{},
+		fallibility:    map[string]bool{},
+	}
 }
 
 func findAssignmentStruct(dst *cst.Struct, srcs []gen.ReqAndResp) *cst.Struct {
@@ -38,6 +50,7 @@ func (g *GeneratorFactory) Generate(dst *cst.Struct, srcs []gen.ReqAndResp, srcA
 	if src != nil {
 		buff := bytes.NewBufferString("")
 		n := &AssignmentGenerator{
+			factory:  g,
 			pbcst:    g.pbcst,
 			cst:      g.cst,
 			dst:      dst,
@@ -57,6 +70,7 @@ func (g *GeneratorFactory) Generate(dst *cst.Struct, srcs []gen.ReqAndResp, srcA
 
 // 赋值方法生成器
 type AssignmentGenerator struct {
+	factory  *GeneratorFactory      // 结构体之间的转换方法由factory统一生成和复用
 	cst      cst.ConcreteSyntaxTree //
 	pbcst    cst.ConcreteSyntaxTree
 	writer   io.Writer
@@ -80,14 +94,26 @@ func findStruct(packageName string, structName string, csts ...cst.ConcreteSynta
 }
 
 func (g *AssignmentGenerator) Generate() error {
-	for _, srcField := range g.src.Fields {
-		for _, dstField := range g.dst.Fields {
-			if srcField.Name == dstField.Name {
-				err := g.generateAssignmentSegment(g.srcAlias, srcField, dstField)
-				if err != nil {
-					return err
-				}
-			}
+	return g.generateFieldsAssignment(g.srcAlias)
+}
+
+// 按照factory的匹配策略为dst的每个字段寻找src中的字段赋值
+// 找不到赋值来源的字段记录到factory中，由调用方决定告警还是报错
+func (g *AssignmentGenerator) generateFieldsAssignment(srcAlias Alias) error {
+	for _, dstField := range g.dst.Fields {
+		if !isAssignableField(dstField) {
+			continue
+		}
+
+		srcField, found := g.factory.MatchField(g.src, g.dst, dstField)
+		if !found {
+			g.factory.addUnmappedField(g.src, g.dst, dstField)
+			continue
+		}
+
+		err := g.generateAssignmentSegment(srcAlias, srcField, dstField)
+		if err != nil {
+			return err
 		}
 	}
 	return nil
@@ -106,141 +132,118 @@ func (g *AssignmentGenerator) findStruct(packageName string, structName string)
 	return nil
 }
 
+// 是否是pbcst中由IDL生成的结构体，optional字段可以通过getter取值
+func (g *AssignmentGenerator) isGeneratedStruct(s *cst.Struct) bool {
+	return g.factory.opts.schema != SchemaPlain &&
+		s != nil && g.pbcst != nil && s.PackageName == g.pbcst.PackageName()
+}
+
+// 是否是pb.go中定义的结构体，optional字段可以通过proto.Int64等辅助方法赋值
+func (g *AssignmentGenerator) isProtobufStruct(s *cst.Struct) bool {
+	return g.factory.opts.schema == SchemaProtobuf && g.isGeneratedStruct(s)
+}
+
+// protobuf optional字段对应的辅助方法 e.g. int64 => proto.Int64
+func protoHelper(typeName string) (string, bool) {
+	switch typeName {
+	case "int32":
+		return "proto.Int32", true
+	case "int64":
+		return "proto.Int64", true
+	case "uint32":
+		return "proto.Uint32", true
+	case "uint64":
+		return "proto.Uint64", true
+	case "float32":
+		return "proto.Float32", true
+	case "float64":
+		return "proto.Float64", true
+	case "bool":
+		return "proto.Bool", true
+	case "string":
+		return "proto.String", true
+	}
+	return "", false
+}
+
 // 生成转换基本类型的方法体
+// 数值收窄时检查取值范围，超出范围时将错误记录到上下文中的err
+// 指针在解引用之前总是检查nil
 func (g *AssignmentGenerator) generateBasicTypeAssignmentConvertFunc(srcAlias Alias, srcType cst.BaseType, dstType cst.BaseType) {
 	var aliasName = srcAlias.String()
-	if srcType.Name == dstType.Name {
-		if srcType.Star && !dstType.Star {
-			// F float64    req.F *float64
-			// F: *req.F,
-			if statement, isNeed := srcAlias.CheckNil(); isNeed {
-				g.print("func() (v %s) { if %s { v = *%s } ; return v }()",
-					dstType, statement, aliasName)
-			} else {
-				g.print(" *%s ", aliasName)
-			}
+	if srcType.Star && dstType.Star {
+		g.generateOptionalAssignmentConvertFunc(srcAlias, srcType, dstType)
+		return
+	}
 
-		} else if !srcType.Star && dstType.Star {
-			// F *float64   req.F float64
-			// F: &req.F,
-			if statement, isNeed := srcAlias.CheckNil(); isNeed {
-				g.print("func() (v %s) { if %s { v = &%s } ; return v }()",
-					dstType, statement, aliasName)
-			} else {
-				g.print(" &%s ", aliasName)
-			}
+	statement, isNeed := checkNil(srcAlias, srcType.Star)
+	value := aliasName
+	if srcType.Star {
+		value = "*" + aliasName
+	}
+	value = g.factory.basicConvert(value, srcType.Name, dstType.Name)
+
+	switch {
+	case dstType.Star && g.isProtobufStruct(g.dst) && protoHelperFound(dstType.Name):
+		// F *int64(proto3 optional)   req.F int
+		// F: proto.Int64(int64(req.F)),
+		helper, _ := protoHelper(dstType.Name)
+		value = fmt.Sprintf("%s(%s)", helper, value)
+	case dstType.Star && value == aliasName:
+		// F *float64   req.F float64
+		// F: &req.F,
+		value = "&" + aliasName
+	case dstType.Star:
+		// Y *int64    req.Y int
+		// Y: func() (v *int64) { k := int64(req.Y); v = &k ; return v }(),
+		if isNeed {
+			g.print("func() (v %s) { if %s { k := %s; v = &k } ; return v }()", dstType, statement, value)
 		} else {
-			// Message string    resp.Message string
-			//Message: resp.Message,
-			if statement, isNeed := srcAlias.CheckNil(); isNeed {
-				g.print("func() (v %s) { if %s { v = %s } ; return v }()",
-					dstType, statement, aliasName)
-			} else {
-				g.print(" %s ", aliasName)
-			}
+			g.print("func() (v %s) { k := %s; v = &k ; return v }()", dstType, value)
 		}
+		return
+	}
+
+	// Code int64  resp.Code int
+	// Code: int64(resp.Code),
+	// T int64  req.T *int
+	// T: func() (v int64) { if req != nil && req.T != nil { v = int64(*req.T) } ; return v }(),
+	if isNeed {
+		g.print("func() (v %s) { if %s { v = %s } ; return v }()", dstType, statement, value)
 	} else {
-		if srcType.Star && !dstType.Star {
-			// T int64  req.T *int
-			// T: int64(*req.T),
-			if statement, isNeed := srcAlias.CheckNil(); isNeed {
-				g.print("func() (v %s) { if %s { v = %s(*%s) } ; return v }()",
-					dstType, statement, dstType.Name, aliasName)
-			} else {
-				g.print(" %s(*%s) ", dstType.Name, aliasName)
-			}
-		} else if !srcType.Star && dstType.Star {
-			// Y *int64    req.Y int
-			// Y: func(i int) *int64 { return &i }(req.Y),
-			if statement, isNeed := srcAlias.CheckNil(); isNeed {
-				g.print("func() (v %s) { if %s { k := %s(%s); v = &k } ; return v }()",
-					dstType, statement, dstType.Name, aliasName)
-			} else {
-				g.print("func() (v %s) { k := %s(%s);v = &k ; return v }()",
-					dstType, dstType.Name, aliasName)
-			}
-		} else {
-			// Code int64  resp.Code int
-			// Code: int64(resp.Code),
-			if statement, isNeed := srcAlias.CheckNil(); isNeed {
-				g.print("func() (v %s) { if %s { v = %s(%s) } ; return v }()",
-					dstType, statement, dstType.Name, aliasName)
-			} else {
-				g.print(" %s(%s) ", dstType.Name, aliasName)
-			}
-		}
+		g.print(" %s ", value)
 	}
-	return
 }
 
-// 生成结构体转换的方法体
-func (g *AssignmentGenerator) generateStructTypeAssignmentConvertFunc(srcAlias Alias, srcType, dstType cst.BaseType, srcStruct, dstStruct *cst.Struct) error {
-	// 非当前包去生成赋值语句时，需要补全引用的包名
-	dstType.X = dstStruct.PackageName
-	srcType.X = srcStruct.PackageName
-	g.println("func(src %s) (dst %s) {", srcType.String(), dstType.String())
-	{
-		// 这里生成赋值方法的需要重新生成别名对象，需要从当前的src,dst信息中生成
-		newAlias := NewObjectAlias(g.cst, g.pbcst)(
-			"src",
-			srcStruct.PackageName,
-			srcStruct.Name,
-			srcType.Star,
-		)
-		// 判断是否需要生成别名.字段名时的nil pointer检查语句
-		statement, isNeedWrap := newAlias.CheckNil()
-		if isNeedWrap {
-			g.println("if %s {", statement)
-		}
+func protoHelperFound(typeName string) bool {
+	_, found := protoHelper(typeName)
+	return found
+}
 
-		{
-			if dstStruct.Type != nil && srcStruct.Type != nil {
-				// 枚举类型互相转换
-				srcStruct.Type.BaseType.Star = srcType.Star
-				if dstType.Star {
-					removeStarType := dstType
-					removeStarType.Star = false
-					g.print("temp := %s(", removeStarType.String())
-					g.generateBasicTypeAssignmentConvertFunc(newAlias, srcStruct.Type.BaseType, dstStruct.Type.BaseType)
-					g.println(")")
-					g.print("dst = &temp")
-				} else {
-					g.print("dst = %s(", dstType)
-					g.generateBasicTypeAssignmentConvertFunc(newAlias, srcStruct.Type.BaseType, dstStruct.Type.BaseType)
-					g.print(")")
-				}
-			} else {
-				if dstType.Star {
-					removeStarType := dstType
-					removeStarType.Star = false
-					g.println("dst = &%s{", removeStarType.String())
-				} else {
-					g.println("dst = %s{", dstType)
-				}
-				for _, srcField := range srcStruct.Fields {
-					for _, dstField := range dstStruct.Fields {
-						if srcField.Name == dstField.Name {
-							err := g.generateAssignmentSegment(
-								newAlias,
-								srcField,
-								dstField,
-							)
-							if err != nil {
-								return err
-							}
-						}
-					}
-				}
-				g.println("}")
-			}
-		}
+// 生成指针类型(proto3 optional)之间转换的方法体
+// 保留nil语义，并复制指针指向的值，防止两端共享同一个指针
+// F *int64    req.F *int
+// F: func() (v *int64) { if req != nil && req.F != nil { v = proto.Int64(int64(*req.F)) }; return v }(),
+func (g *AssignmentGenerator) generateOptionalAssignmentConvertFunc(srcAlias Alias, srcType cst.BaseType, dstType cst.BaseType) {
+	var aliasName = srcAlias.String()
+	statement, _ := checkNil(srcAlias, true)
+	value := g.factory.basicConvert("*"+aliasName, srcType.Name, dstType.Name)
 
-		if isNeedWrap {
-			g.println("}")
-		}
+	if helper, found := protoHelper(dstType.Name); found && g.isProtobufStruct(g.dst) {
+		g.print("func() (v %s) { if %s { v = %s(%s) } ; return v }()",
+			dstType, statement, helper, value)
+	} else {
+		g.print("func() (v %s) { if %s { k := %s; v = &k } ; return v }()",
+			dstType, statement, value)
 	}
-	g.print("; return dst}(%s)", srcAlias.String())
-	return nil
+}
+
+// 生成通过protobuf及thrift getter取值的语句，optional字段未设置时getter返回零值
+// F int    req.F *int64(proto3 optional)
+// F: int(req.GetF()),
+func (g *AssignmentGenerator) generateGetterAssignmentConvertFunc(srcAlias Alias, src cst.Field, dst cst.Field) {
+	getter := fmt.Sprintf("%s.Get%s()", srcAlias, src.Name)
+	g.print(" %s ", g.factory.basicConvert(getter, src.Type.Name, dst.Type.Name))
 }
 
 // TODO 如果有同样包名的就会有问题
@@ -264,164 +267,198 @@ func ifEmpty(ss ...string) string {
 	return ""
 }
 
+func (g *AssignmentGenerator) structConverter(srcStruct, dstStruct *cst.Struct, srcType, dstType cst.BaseType) (*converter, error) {
+	if srcStruct == nil || dstStruct == nil {
+		return nil, fmt.Errorf("Not found struct of type(%s => %s)", srcType.String(), dstType.String())
+	}
+	return g.factory.converter(srcStruct, dstStruct)
+}
+
 func (g *AssignmentGenerator) generateAssignmentSegment(srcAlias Alias, src cst.Field, dst cst.Field) error {
 	switch dst.Type.GoType {
 	case cst.BasicType:
 		g.print("%s: ", dst.Name)
-		g.generateBasicTypeAssignmentConvertFunc(srcAlias.With(src.Name), src.Type.BaseType, dst.Type.BaseType)
+		if src.Type.GoType != cst.BasicType {
+			// 自定义类型转换为基础类型 e.g. decimal.Decimal => string
+			err := g.generateValueConvert(srcAlias.With(src.Name), src.Type, dst.Type)
+			if err != nil {
+				return err
+			}
+		} else if src.Type.Star && !dst.Type.Star && g.isGeneratedStruct(g.src) {
+			g.generateGetterAssignmentConvertFunc(srcAlias, src, dst)
+		} else {
+			g.generateBasicTypeAssignmentConvertFunc(srcAlias.With(src.Name), src.Type.BaseType, dst.Type.BaseType)
+		}
 		g.println(",")
-	case cst.StructType:
-		srcType := src.Type.BaseType
-		dstType := dst.Type.BaseType
-		// 寻找类型的数据结构
-		srcStruct := g.findStruct(inferPackageName(srcType, g.src.PackageName), srcType.Name)
-		dstStruct := g.findStruct(inferPackageName(dstType, g.dst.PackageName), dstType.Name)
+	case cst.StructType, cst.ArrayType, cst.MapType:
 		g.print("%s: ", dst.Name)
-		err := g.generateStructTypeAssignmentConvertFunc(srcAlias.With(src.Name), srcType, dstType, srcStruct, dstStruct)
+		err := g.generateValueConvert(srcAlias.With(src.Name), src.Type, dst.Type)
 		if err != nil {
 			return err
 		}
 		g.println(",")
-	case cst.ArrayType:
-		switch dst.Type.ElementType.GoType {
-		case cst.BasicType:
-			// 基础类型相同的数组直接赋值，不需要转换
-			// []string = []string, []int = []int ...
-			if src.Type.Name == dst.Type.Name &&
-				src.Type.Star == dst.Type.Star {
-				g.println("%s: %s.%s,", dst.Name, srcAlias, src.Name)
-				return nil
-			}
+	}
+	return nil
+}
 
-			// 从父级别名新增引用
-			srcAlias = srcAlias.With(src.Name)
-			g.println("%s: func(src %s) (dst %s) {", dst.Name, src.Type.String(), dst.Type.String())
-			{
-				g.println("dst = make( %s, len(src))", dst.Type.String())
-				g.println("for i, _ := range src{")
-				{
-					g.println("temp := src[i]")
-					g.print("dst[i] = ")
-					g.generateBasicTypeAssignmentConvertFunc(
-						NewSimpleAlias("temp"),
-						*src.Type.ElementType,
-						*dst.Type.ElementType,
-					)
-					g.println("")
-				}
-				g.println("}")
-				g.println("return")
-			}
-			g.println("}(%s),", srcAlias)
-		case cst.StructType:
-			// 数组的值是对象类型，生成转换方法
-			srcStruct := g.findStruct(inferPackageName(*src.Type.ElementType, g.src.PackageName), src.Type.ElementType.Name)
-			dstStruct := g.findStruct(inferPackageName(*dst.Type.ElementType, g.dst.PackageName), dst.Type.ElementType.Name)
-
-			src.Type.ElementType.X = srcStruct.PackageName
-			dst.Type.ElementType.X = dstStruct.PackageName
-			// 从父级别名新增引用
-			srcAlias = srcAlias.With(src.Name)
-			g.println("%s: func(src %s) (dst %s) {", dst.Name, src.Type.String(), dst.Type.String())
-			{
-				g.println("dst = make( %s, len(src))", dst.Type.String())
-				g.println("for i, _ := range src{")
-				{
-					g.println("temp := src[i]")
-					g.print("dst[i] = ")
-					err := g.generateStructTypeAssignmentConvertFunc(
-						srcAlias.ReplaceName("temp"),
-						*src.Type.ElementType,
-						*dst.Type.ElementType,
-						srcStruct,
-						dstStruct,
-					)
-					if err != nil {
-						return err
-					}
-					g.println("")
-				}
-				g.println("}")
-				g.println("return")
-			}
-			g.println("}(%s),", srcAlias)
-		default:
-			return errors.New("unsupport type" + dst.Type.String())
+// 生成将别名引用的值从srcType转换为dstType的表达式
+// 数组和map的元素类型可以是任意嵌套的数组、map、结构体及其指针
+func (g *AssignmentGenerator) generateValueConvert(srcAlias Alias, srcType, dstType cst.Type) error {
+	if g.generateTypeConverterCall(srcAlias, srcType, dstType) {
+		return nil
+	}
+
+	switch dstType.GoType {
+	case cst.BasicType:
+		if srcType.GoType != cst.BasicType {
+			return fmt.Errorf("unsupport type(%s => %s)", srcType.String(), dstType.String())
 		}
-	case cst.MapType:
-		switch dst.Type.ValueType.GoType {
-		case cst.BasicType:
-			// 基础类型相同的map直接赋值，不需要转换
-			// map[string]string = map[string]string
-			if src.Type.Name == dst.Type.Name &&
-				src.Type.Star == dst.Type.Star {
-				g.println("%s: %s.%s,", dst.Name, srcAlias, src.Name)
-				return nil
-			}
+		g.generateBasicTypeAssignmentConvertFunc(srcAlias, srcType.BaseType, dstType.BaseType)
+	case cst.StructType:
+		// 寻找类型的数据结构
+		srcStruct := g.findStruct(inferPackageName(srcType.BaseType, g.src.PackageName), srcType.Name)
+		dstStruct := g.findStruct(inferPackageName(dstType.BaseType, g.dst.PackageName), dstType.Name)
+		c, err := g.structConverter(srcStruct, dstStruct, srcType.BaseType, dstType.BaseType)
+		if err != nil {
+			return err
+		}
+		g.generateConverterCall(srcAlias, srcType.BaseType, dstType.BaseType, dstStruct, c)
+	case cst.ArrayType, cst.MapType:
+		return g.generateCollectionConvert(srcAlias, srcType, dstType)
+	default:
+		return errors.New("unsupport type" + dstType.String())
+	}
+	return nil
+}
 
-			// 从父级别名新增引用
-			srcAlias = srcAlias.With(src.Name)
-			g.println("%s: func(src %s) (dst %s) {", dst.Name, src.Type.String(), dst.Type.String())
-			{
-				g.println("dst = make( %s, len(src))", dst.Type.String())
-				g.println("for i, _ := range src{")
-				{
-					g.println("temp := src[i]")
-					g.print("dst[")
-					g.generateBasicTypeAssignmentConvertFunc(
-						NewSimpleAlias("i"),
-						*src.Type.KeyType,
-						*dst.Type.KeyType,
-					)
-					g.print("] = ")
-					g.generateBasicTypeAssignmentConvertFunc(
-						NewSimpleAlias("temp"),
-						*src.Type.ValueType,
-						*dst.Type.ValueType,
-					)
-					g.println("")
-				}
-				g.println("}")
-				g.println("return")
-			}
-			g.println("}(%s),", srcAlias)
-		case cst.StructType:
-			// map的值是对象类型，生成转换方法
-			srcStruct := g.findStruct(inferPackageName(*src.Type.ValueType, g.src.PackageName), src.Type.ValueType.Name)
-			dstStruct := g.findStruct(inferPackageName(*dst.Type.ValueType, g.dst.PackageName), dst.Type.ValueType.Name)
-
-			src.Type.ValueType.X = srcStruct.PackageName
-			dst.Type.ValueType.X = dstStruct.PackageName
-			// 从父级别名新增引用
-			srcAlias = srcAlias.With(src.Name)
-			g.println("%s: func(src %s) (dst %s) {", dst.Name, src.Type.String(), dst.Type.String())
-			{
-				g.println("dst = make(%s, len(src))", dst.Type.String())
-				g.println("for k, v := range src{")
-				{
-					g.print("dst[k] =")
-					err := g.generateStructTypeAssignmentConvertFunc(
-						srcAlias.ReplaceName("v"),
-						*src.Type.ValueType,
-						*dst.Type.ValueType,
-						srcStruct,
-						dstStruct,
-					)
-					if err != nil {
-						return err
-					}
-				}
-				g.println("}")
-				g.println("return")
+// 生成数组或者map之间转换的表达式，元素是数组或者map时递归生成
+// 每一层都保留nil语义，nil的数组或者map转换后仍然是nil
+// Matrix [][]int64    req.Matrix [][]int
+// Matrix: func(src [][]int) (dst [][]int64) { ...; for i, item := range src { dst[i] = func(src []int) (dst []int64) {...}(item) }; return }(req.Matrix),
+func (g *AssignmentGenerator) generateCollectionConvert(srcAlias Alias, srcType, dstType cst.Type) error {
+	if srcType.GoType != dstType.GoType ||
+		(srcType.GoType == cst.ArrayType && (srcType.ElementType == nil || dstType.ElementType == nil)) ||
+		(srcType.GoType == cst.MapType && (srcType.ValueType == nil || dstType.ValueType == nil)) {
+		return fmt.Errorf("unsupport type(%s => %s)", srcType.String(), dstType.String())
+	}
+
+	srcTypeName, err := g.typeName(srcType, g.src.PackageName)
+	if err != nil {
+		return err
+	}
+	dstTypeName, err := g.typeName(dstType, g.dst.PackageName)
+	if err != nil {
+		return err
+	}
+
+	statement, isNeed := srcAlias.CheckNil()
+	if isNeed {
+		g.print("func() (v %s) { if %s { v = ", dstTypeName, statement)
+		defer g.print("} ; return v }()")
+	}
+
+	// 基础类型相同的数组或者map直接赋值，不需要转换
+	// []string = []string, map[string][]int = map[string][]int ...
+	if isPlainType(srcType) && srcTypeName == dstTypeName {
+		g.print(" %s ", srcAlias)
+		return nil
+	}
+
+	g.println("func(src %s) (dst %s) {", srcTypeName, dstTypeName)
+	{
+		g.println("if src == nil {")
+		g.println("return")
+		g.println("}")
+		g.println("dst = make(%s, len(src))", dstTypeName)
+		switch dstType.GoType {
+		case cst.ArrayType:
+			g.println("for i, item := range src {")
+			g.print("dst[i] = ")
+			err = g.generateValueConvert(
+				NewSimpleAlias("item"),
+				srcType.ElementType.FullType(),
+				dstType.ElementType.FullType(),
+			)
+		case cst.MapType:
+			if srcType.KeyType.GoType != cst.BasicType || dstType.KeyType.GoType != cst.BasicType {
+				return fmt.Errorf("unsupport key type of map(%s => %s)", srcType.String(), dstType.String())
 			}
-			g.println("}(%s),", srcAlias)
-		default:
-			return errors.New("unsupport type" + dst.Type.String())
+			g.println("for key, item := range src {")
+			g.print("dst[")
+			g.generateBasicTypeAssignmentConvertFunc(NewSimpleAlias("key"), *srcType.KeyType, *dstType.KeyType)
+			g.print("] = ")
+			err = g.generateValueConvert(
+				NewSimpleAlias("item"),
+				srcType.ValueType.FullType(),
+				dstType.ValueType.FullType(),
+			)
+		}
+		if err != nil {
+			return err
 		}
+		g.println("")
+		g.println("}")
+		g.println("return")
 	}
+	g.print("}(%s)", srcAlias)
 	return nil
 }
 
+// 是否仅由基础类型组成 e.g. int, []string, map[string][]int
+func isPlainType(t cst.Type) bool {
+	switch t.GoType {
+	case cst.BasicType:
+		return true
+	case cst.ArrayType:
+		return t.ElementType != nil && isPlainType(t.ElementType.FullType())
+	case cst.MapType:
+		return t.KeyType != nil && t.KeyType.GoType == cst.BasicType &&
+			t.ValueType != nil && isPlainType(t.ValueType.FullType())
+	}
+	return false
+}
+
+// 带包名的完整类型名，结构体使用其定义所在的包名
+// e.g. []*addpb.Address, map[string][]*addservice.Geo
+func (g *AssignmentGenerator) typeName(t cst.Type, pkg string) (string, error) {
+	switch t.GoType {
+	case cst.BasicType:
+		return t.String(), nil
+	case cst.StructType:
+		s := g.findStruct(inferPackageName(t.BaseType, pkg), t.Name)
+		if s == nil && t.X != "" {
+			// 其他包中没有解析的类型，e.g. 自定义转换的decimal.Decimal, *timestamppb.Timestamp
+			return t.String(), nil
+		}
+		if s == nil {
+			return "", fmt.Errorf("Not found struct of type(%s)", t.String())
+		}
+		if t.Star {
+			return "*" + qualifiedTypeName(s), nil
+		}
+		return qualifiedTypeName(s), nil
+	case cst.ArrayType:
+		if t.ElementType == nil {
+			break
+		}
+		elem, err := g.typeName(t.ElementType.FullType(), pkg)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	case cst.MapType:
+		if t.KeyType == nil || t.ValueType == nil {
+			break
+		}
+		value, err := g.typeName(t.ValueType.FullType(), pkg)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("map[%s]%s", t.KeyType.String(), value), nil
+	}
+	return "", fmt.Errorf("unsupport type(%s)", t.String())
+}
+
 func (g *AssignmentGenerator) print(format string, a ...interface{}) {
 	g.writer.Write([]byte(fmt.Sprintf(format, a...)))
 }
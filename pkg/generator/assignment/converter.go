@@ -0,0 +1,326 @@
+package assignment
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	"ezrpro.com/micro/kit/pkg/utils"
+)
+
+// 一对结构体之间的命名转换方法
+// 结构体: func pbAddressToAddress(src *pb.Address) *service.Address
+// 枚举:   func pbPhoneTypeToPhoneType(src pb.PhoneType) service.PhoneType
+// 转换可能失败时返回error
+// 结构体: func pbAddressToAddress(src *pb.Address) (dst *service.Address, err error)
+// 枚举:   func pbPhoneTypeToPhoneType(src pb.PhoneType) (service.PhoneType, error)
+type converter struct {
+	name     string
+	isEnum   bool
+	fallible bool // 转换可能失败，方法返回(T, error)
+	body     string
+}
+
+// 获取src到dst的转换方法，不存在时生成
+func (f *GeneratorFactory) converter(srcStruct, dstStruct *cst.Struct) (*converter, error) {
+	name := f.converterName(srcStruct, dstStruct)
+	f.probe.call(name)
+	if c, found := f.converters[name]; found {
+		return c, nil
+	}
+
+	c := &converter{
+		name:     name,
+		isEnum:   srcStruct.Type != nil && dstStruct.Type != nil,
+		fallible: f.fallible(srcStruct, dstStruct),
+	}
+	// 先注册再生成方法体，方法体中引用自身类型时直接使用方法名
+	f.converters[name] = c
+
+	if f.probe != nil {
+		f.probe.fallible[name] = false
+		f.probe.stack = append(f.probe.stack, name)
+		defer func() { f.probe.stack = f.probe.stack[:len(f.probe.stack)-1] }()
+	}
+
+	buff := bytes.NewBufferString("")
+	g := &AssignmentGenerator{
+		factory: f,
+		pbcst:   f.pbcst,
+		cst:     f.cst,
+		src:     srcStruct,
+		dst:     dstStruct,
+		writer:  buff,
+	}
+	err := g.generateConverter(c)
+	if err != nil {
+		delete(f.converters, name)
+		return nil, err
+	}
+	c.body = buff.String()
+	return c, nil
+}
+
+// 转换方法中存在可能失败的转换(整数收窄、返回error的自定义类型转换、未定义的枚举值返回error)，
+// 或者调用了可能失败的转换方法时，方法返回error
+// 结构体可能递归引用自身，需要在生成方法体之前确定方法签名，因此先试生成一遍记录调用关系
+func (f *GeneratorFactory) fallible(srcStruct, dstStruct *cst.Struct) bool {
+	// 试生成时不关心方法签名
+	if f.probe != nil {
+		return false
+	}
+
+	name := f.converterName(srcStruct, dstStruct)
+	if fallible, found := f.fallibility[name]; found {
+		return fallible
+	}
+
+	dryRun := &GeneratorFactory{
+		cst:            f.cst,
+		pbcst:          f.pbcst,
+		opts:           f.opts,
+		converters:     map[string]*converter{},
+		unmappedFields: map[string]struct{}{},
+		probe: &probe{
+			fallible: map[string]bool{},
+			calls:    map[string][]string{},
+		},
+	}
+	// 生成失败时由正式生成返回错误
+	dryRun.converter(srcStruct, dstStruct)
+	for name, fallible := range dryRun.probe.resolve() {
+		f.fallibility[name] = fallible
+	}
+	return f.fallibility[name]
+}
+
+// 试生成转换方法时记录每个方法中是否存在可能失败的转换，以及调用的其他转换方法
+type probe struct {
+	stack    []string            // 正在生成的转换方法
+	fallible map[string]bool     // 生成的所有转换方法，方法本身是否存在可能失败的转换
+	calls    map[string][]string // 调用的其他转换方法
+}
+
+// 正在生成的转换方法中存在可能失败的转换
+func (p *probe) markFallible() {
+	if p == nil || len(p.stack) == 0 {
+		return
+	}
+	p.fallible[p.stack[len(p.stack)-1]] = true
+}
+
+// 正在生成的转换方法调用了其他转换方法
+func (p *probe) call(name string) {
+	if p == nil || len(p.stack) == 0 {
+		return
+	}
+	caller := p.stack[len(p.stack)-1]
+	p.calls[caller] = append(p.calls[caller], name)
+}
+
+// 调用了可能失败的转换方法的方法同样可能失败，直到没有新的方法被标记
+func (p *probe) resolve() map[string]bool {
+	fallibility := map[string]bool{}
+	for name, fallible := range p.fallible {
+		fallibility[name] = fallible
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for caller, callees := range p.calls {
+			if fallibility[caller] {
+				continue
+			}
+			for _, callee := range callees {
+				if fallibility[callee] {
+					fallibility[caller] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return fallibility
+}
+
+// pb.Address => service.Address: pbAddressToAddress
+// service.Address => pb.Address: addressToPbAddress
+func (f *GeneratorFactory) converterName(srcStruct, dstStruct *cst.Struct) string {
+	src := f.structPrefix(srcStruct) + utils.ToUpperFirst(srcStruct.Name)
+	dst := utils.ToUpperFirst(f.structPrefix(dstStruct)) + utils.ToUpperFirst(dstStruct.Name)
+	if f.opts.exported {
+		return strings.ToUpper(src[:1]) + src[1:] + "To" + dst
+	}
+	return strings.ToLower(src[:1]) + src[1:] + "To" + dst
+}
+
+func (f *GeneratorFactory) structPrefix(s *cst.Struct) string {
+	switch {
+	case f.pbcst != nil && s.PackageName == f.pbcst.PackageName():
+		return f.opts.converterPrefix
+	case s.PackageName == f.cst.PackageName():
+		return ""
+	default:
+		// 引用其他包的结构体，带上包名防止重名
+		return s.PackageName
+	}
+}
+
+// 生成任意两个结构体之间的转换方法，返回方法名
+// 方法体以及其依赖的其他转换方法通过Converters输出
+// e.g. model.User => service.User: userToServiceUser
+func (f *GeneratorFactory) GenerateConverter(srcStruct, dstStruct *cst.Struct) (string, error) {
+	c, err := f.converter(srcStruct, dstStruct)
+	if err != nil {
+		return "", err
+	}
+	return c.name, nil
+}
+
+// 按方法名排序输出所有生成的转换方法，保证生成的代码稳定
+func (f *GeneratorFactory) Converters() string {
+	return JoinConverters(f.ConverterBodies())
+}
+
+// 所有生成的转换方法 key: 方法名 val: 方法体
+// 多个factory输出到同一个文件时，通过方法名去掉重复的辅助方法 e.g. int64ToInt32
+func (f *GeneratorFactory) ConverterBodies() map[string]string {
+	bodies := map[string]string{}
+	for name, c := range f.converters {
+		bodies[name] = c.body
+	}
+	return bodies
+}
+
+func JoinConverters(bodies map[string]string) string {
+	var names []string
+	for name := range bodies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buff := bytes.NewBufferString("")
+	for _, name := range names {
+		buff.WriteString(bodies[name])
+		buff.WriteString("\n")
+	}
+	return buff.String()
+}
+
+func (g *AssignmentGenerator) generateConverter(c *converter) error {
+	srcType := qualifiedTypeName(g.src)
+	dstType := qualifiedTypeName(g.dst)
+
+	if c.isEnum {
+		g.generateEnumConverter(c)
+		return nil
+	}
+
+	// 已经检查过src，字段引用时不需要再检查src本身
+	alias := NewObjectAlias(g.cst, g.pbcst)("src", g.src.PackageName, g.src.Name, false)
+	if !c.fallible {
+		g.println("func %s(src *%s) *%s {", c.name, srcType, dstType)
+		g.println("if src == nil {")
+		g.println("return nil")
+		g.println("}")
+		g.println("return &%s{", dstType)
+		if err := g.generateFieldsAssignment(alias); err != nil {
+			return err
+		}
+		g.println("}")
+		g.println("}")
+		return nil
+	}
+
+	// 字段转换失败时将错误记录到返回值err中
+	g.println("func %s(src *%s) (dst *%s, err error) {", c.name, srcType, dstType)
+	g.println("if src == nil {")
+	g.println("return nil, nil")
+	g.println("}")
+	g.println("dst = &%s{", dstType)
+	if err := g.generateFieldsAssignment(alias); err != nil {
+		return err
+	}
+	g.println("}")
+	g.println("if err != nil {")
+	g.println("return nil, err")
+	g.println("}")
+	g.println("return dst, nil")
+	g.println("}")
+	return nil
+}
+
+func qualifiedTypeName(s *cst.Struct) string {
+	return s.PackageName + "." + s.Name
+}
+
+// 生成调用转换方法的表达式，根据两端是否为指针适配转换方法的签名
+// Addr: pbAddressToAddress(req.Addr),
+// Addr: *pbAddressToAddress(&req.Addr),
+// Phone: pbPhoneTypeToPhoneType(req.Phone),
+func (g *AssignmentGenerator) generateConverterCall(srcAlias Alias, srcType, dstType cst.BaseType, dstStruct *cst.Struct, c *converter) {
+	var (
+		aliasName         = srcAlias.String()
+		statement, isNeed = srcAlias.CheckNil()
+	)
+	dstType.X = dstStruct.PackageName
+
+	if c.isEnum {
+		value := aliasName
+		if srcType.Star {
+			value = "*" + aliasName
+			statement, isNeed = checkNil(srcAlias, true)
+		}
+		call := c.call(value, qualifiedTypeName(dstStruct))
+
+		switch {
+		case !isNeed && !dstType.Star:
+			g.print(" %s ", call)
+		case !isNeed && dstType.Star:
+			g.print("func() (v %s) { k := %s; v = &k ; return v }()", dstType, call)
+		case dstType.Star:
+			g.print("func() (v %s) { if %s { k := %s; v = &k } ; return v }()", dstType, statement, call)
+		default:
+			g.print("func() (v %s) { if %s { v = %s } ; return v }()", dstType, statement, call)
+		}
+		return
+	}
+
+	value := aliasName
+	if !srcType.Star {
+		value = "&" + aliasName
+	}
+	call := c.call(value, "*"+qualifiedTypeName(dstStruct))
+
+	switch {
+	case !isNeed && dstType.Star:
+		g.print(" %s ", call)
+	case dstType.Star:
+		g.print("func() (v %s) { if %s { v = %s } ; return v }()", dstType, statement, call)
+	case !isNeed && !srcType.Star && !c.fallible:
+		// 非nil的输入必然得到非nil的输出
+		g.print(" *%s ", call)
+	case !isNeed:
+		g.print("func() (v %s) { if p := %s; p != nil { v = *p } ; return v }()", dstType, call)
+	default:
+		g.print("func() (v %s) { if %s { if p := %s; p != nil { v = *p } } ; return v }()",
+			dstType, statement, call)
+	}
+}
+
+// 调用转换方法的表达式，可能失败的转换将错误记录到上下文中的err变量，并返回零值
+// 上下文中的err由转换方法的返回值或者decode/encode方法声明
+// func() (r addservice.PhoneType) { var e error; if r, e = pbPhoneTypeToPhoneType(req.Phone); e != nil && err == nil { err = e }; return r }()
+func (c *converter) call(value, resultType string) string {
+	if !c.fallible {
+		return fmt.Sprintf("%s(%s)", c.name, value)
+	}
+	return fallibleCall(c.name, value, resultType)
+}
+
+func fallibleCall(fn, value, resultType string) string {
+	return fmt.Sprintf("func() (r %s) { var e error; if r, e = %s(%s); e != nil && err == nil { err = e } ; return r }()",
+		resultType, fn, value)
+}
@@ -0,0 +1,239 @@
+package assignment
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+)
+
+// 枚举转换时遇到未定义的枚举值的处理方式
+type EnumUnknownBehaviour string
+
+const (
+	// 转换为目标枚举的默认值(值为0的成员)
+	EnumUnknownDefault EnumUnknownBehaviour = "default"
+	// 按数值直接转换
+	EnumUnknownPassthrough EnumUnknownBehaviour = "passthrough"
+	// 返回错误
+	EnumUnknownError EnumUnknownBehaviour = "error"
+)
+
+var AllEnumUnknownBehaviours = []EnumUnknownBehaviour{
+	EnumUnknownDefault,
+	EnumUnknownPassthrough,
+	EnumUnknownError,
+}
+
+func ParseEnumUnknownBehaviour(s string) (EnumUnknownBehaviour, error) {
+	for _, behaviour := range AllEnumUnknownBehaviours {
+		if string(behaviour) == strings.ToLower(strings.TrimSpace(s)) {
+			return behaviour, nil
+		}
+	}
+	return "", fmt.Errorf("Unsupport enum unknown behaviour:%s, must be one of %v", s, AllEnumUnknownBehaviours)
+}
+
+// 枚举成员
+type enumMember struct {
+	name  string // 常量名 e.g. PhoneType_MOBILE
+	key   string // 去掉枚举名前缀后用于匹配的名字 e.g. MOBILE
+	value int
+}
+
+// 找出枚举类型在其所在语法树中定义的所有常量
+// 值相同的常量(别名)只保留第一个，防止生成重复的case
+func (f *GeneratorFactory) enumMembers(s *cst.Struct) []enumMember {
+	t := f.cst
+	if f.pbcst != nil && s.PackageName == f.pbcst.PackageName() {
+		t = f.pbcst
+	} else if s.PackageName != f.cst.PackageName() {
+		// 其他包中的枚举拿不到常量定义
+		return nil
+	}
+
+	var (
+		members []enumMember
+		values  = map[int]struct{}{}
+		i       int
+	)
+	for _, c := range t.Consts() {
+		if c.Type.Name != s.Name || c.Type.X != "" {
+			continue
+		}
+		// 和protobuf生成器一致，优先使用常量定义的值，无法解析时按定义顺序编号
+		value := i
+		if v, err := strconv.Atoi(fmt.Sprint(c.Value)); err == nil {
+			value = v
+		}
+		i++
+
+		if _, found := values[value]; found {
+			continue
+		}
+		values[value] = struct{}{}
+		members = append(members, enumMember{
+			name:  c.Name,
+			key:   enumMemberKey(s.Name, c.Name),
+			value: value,
+		})
+	}
+	return members
+}
+
+// 去掉枚举成员上的各种前缀，并忽略大小写和下划线
+// pb.go:   PhoneType_MOBILE => MOBILE, 嵌套枚举Address_Kind: Address_HOME => HOME
+// thrift:  Address_Kind_HOME => HOME
+// go:      PhoneTypeMobile => MOBILE
+// 由go生成的proto保留了go中的成员名，pb.go中会再加上一层前缀
+// pb.go:   PhoneType_PhoneTypeMobile => MOBILE, 嵌套枚举Address_Kind: Address_KindHome => HOME
+// proto风格的成员名带有大写的枚举名前缀，比较前需要先统一大小写及下划线
+// pb.go:   Kind_KIND_A => A, go: KindA => A
+func enumMemberKey(enumName, constName string) string {
+	var (
+		key      = normalizeEnumName(constName)
+		prefixes = []string{normalizeEnumName(enumName)}
+		// 嵌套枚举在父message中的名字 e.g. Address_Kind => Kind
+		localName = normalizeEnumName(enumName[strings.LastIndex(enumName, "_")+1:])
+	)
+	if i := strings.LastIndex(enumName, "_"); i >= 0 {
+		prefixes = append(prefixes, normalizeEnumName(enumName[:i]))
+	}
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			key = key[len(prefix):]
+			break
+		}
+	}
+	if strings.HasPrefix(key, localName) && len(key) > len(localName) {
+		key = key[len(localName):]
+	}
+	return key
+}
+
+func normalizeEnumName(name string) string {
+	return strings.ToUpper(strings.Replace(name, "_", "", -1))
+}
+
+// 按成员名生成枚举之间的转换方法，未定义的枚举值按照配置的方式处理
+// func pbPhoneTypeToPhoneType(src addpb.PhoneType) addservice.PhoneType {
+// switch src { case addpb.PhoneType_MOBILE: return addservice.PhoneType_MOBILE ... }
+// return addservice.PhoneType(int32(src))
+// }
+func (g *AssignmentGenerator) generateEnumConverter(c *converter) {
+	var (
+		srcType    = qualifiedTypeName(g.src)
+		dstType    = qualifiedTypeName(g.dst)
+		srcMembers = g.factory.enumMembers(g.src)
+		dstMembers = g.factory.enumMembers(g.dst)
+		behaviour  = g.factory.opts.enumUnknown
+	)
+
+	// 任意一端拿不到成员定义时只能按数值转换
+	if len(srcMembers) == 0 || len(dstMembers) == 0 {
+		behaviour = EnumUnknownPassthrough
+	}
+	if behaviour == EnumUnknownError {
+		g.factory.probe.markFallible()
+	}
+
+	if c.fallible {
+		g.println("func %s(src %s) (dst %s, err error) {", c.name, srcType, dstType)
+	} else {
+		g.println("func %s(src %s) %s {", c.name, srcType, dstType)
+	}
+
+	if len(srcMembers) > 0 && len(dstMembers) > 0 {
+		g.println("switch src {")
+		for _, srcMember := range srcMembers {
+			dstMember, found := findEnumMember(dstMembers, srcMember.key)
+			if !found {
+				g.factory.addUnmappedMember(g.src, g.dst, srcMember.name)
+				continue
+			}
+			g.println("case %s.%s:", g.src.PackageName, srcMember.name)
+			if c.fallible {
+				g.println("return %s.%s, nil", g.dst.PackageName, dstMember.name)
+			} else {
+				g.println("return %s.%s", g.dst.PackageName, dstMember.name)
+			}
+		}
+		g.println("}")
+	}
+
+	switch behaviour {
+	case EnumUnknownDefault:
+		zero := zeroValue(g.dst.Type.Name)
+		for _, m := range dstMembers {
+			if m.value == 0 {
+				zero = g.dst.PackageName + "." + m.name
+				break
+			}
+		}
+		if c.fallible {
+			g.println("return %s, nil", zero)
+		} else {
+			g.println("return %s", zero)
+		}
+	case EnumUnknownError:
+		g.println(`return %s, fmt.Errorf("unknown value %%v of enum %s", src)`, zeroValue(g.dst.Type.Name), srcType)
+	default:
+		// 先转换为底层的基础类型，底层类型收窄时检查取值范围
+		value := g.factory.basicConvert(
+			fmt.Sprintf("%s(src)", g.src.Type.Name),
+			g.src.Type.Name,
+			g.dst.Type.Name,
+		)
+		if c.fallible {
+			g.println("dst = %s(%s)", dstType, value)
+			g.println("return dst, err")
+		} else {
+			g.println("return %s(%s)", dstType, value)
+		}
+	}
+	g.println("}")
+}
+
+// 往返转换后保持不变的第一个非零值成员，用于生成测试数据 e.g. addservice.PhoneTypeMobile
+// 零值与未赋值无法区分，没有满足条件的成员时返回false
+func (f *GeneratorFactory) EnumSample(s, pbStruct *cst.Struct) (string, bool) {
+	var (
+		members   = f.enumMembers(s)
+		pbMembers = f.enumMembers(pbStruct)
+	)
+	for _, m := range members {
+		if m.value == 0 {
+			continue
+		}
+		pbMember, found := findEnumMember(pbMembers, m.key)
+		if !found {
+			continue
+		}
+		if back, _ := findEnumMember(members, pbMember.key); back.name == m.name {
+			return s.PackageName + "." + m.name, true
+		}
+	}
+	return "", false
+}
+
+func findEnumMember(members []enumMember, key string) (enumMember, bool) {
+	for _, m := range members {
+		if m.key == key {
+			return m, true
+		}
+	}
+	return enumMember{}, false
+}
+
+// 枚举底层基础类型的零值
+func zeroValue(basicType string) string {
+	switch basicType {
+	case "string":
+		return `""`
+	case "bool":
+		return "false"
+	}
+	return "0"
+}
@@ -0,0 +1,38 @@
+package assignment
+
+import "testing"
+
+func TestEnumMemberKey(t *testing.T) {
+	tests := []struct {
+		enumName  string
+		constName string
+		want      string
+	}{
+		// go
+		{"PhoneType", "PhoneTypeMobile", "MOBILE"},
+		{"Kind", "KindA", "A"},
+		{"Address_Kind", "Address_HOME", "HOME"},
+		// pb.go
+		{"PhoneType", "PhoneType_MOBILE", "MOBILE"},
+		{"Kind", "Kind_KIND_A", "A"},
+		{"Kind", "Kind_A", "A"},
+		{"Address_Kind", "Address_HOME", "HOME"},
+		{"Address_Kind", "Address_KIND_HOME", "HOME"},
+		// 由go生成的proto
+		{"PhoneType", "PhoneType_PhoneTypeMobile", "MOBILE"},
+		{"Address_Kind", "Address_KindHome", "HOME"},
+		// thrift
+		{"Address_Kind", "Address_Kind_HOME", "HOME"},
+		// 成员名与枚举名相同时不去掉前缀
+		{"Kind", "Kind", "KIND"},
+		{"Kind", "Kind_KIND", "KIND"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.enumName+"."+tt.constName, func(t *testing.T) {
+			if got := enumMemberKey(tt.enumName, tt.constName); got != tt.want {
+				t.Errorf("enumMemberKey(%q, %q) = %q, want %q", tt.enumName, tt.constName, got, tt.want)
+			}
+		})
+	}
+}
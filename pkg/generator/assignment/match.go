@@ -0,0 +1,209 @@
+package assignment
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	gen "ezrpro.com/micro/kit/pkg/generator"
+)
+
+// 源结构体和目标结构体之间字段的匹配策略
+type MatchStrategy string
+
+const (
+	// 字段名完全一致 UserID => UserID
+	MatchExact MatchStrategy = "exact"
+	// 忽略大小写 UserID => UserId Userid
+	MatchCaseInsensitive MatchStrategy = "case_insensitive"
+	// 按单词拆分后常见缩写统一为大写 UserId => UserID, Url => URL
+	MatchInitialism MatchStrategy = "initialism"
+	// 按序列化时使用的字段名匹配
+	// service: pb tag中的name, e.g. `pb:"name=user_id"`
+	// pb.go: protobuf tag中的name, e.g. `protobuf:"bytes,1,opt,name=user_id,proto3"`
+	// thrift: thrift tag中的name, e.g. `thrift:"user_id,1"`
+	MatchTag MatchStrategy = "tag"
+)
+
+var AllMatchStrategies = []MatchStrategy{
+	MatchExact,
+	MatchCaseInsensitive,
+	MatchInitialism,
+	MatchTag,
+}
+
+func ParseMatchStrategy(s string) (MatchStrategy, error) {
+	for _, strategy := range AllMatchStrategies {
+		if string(strategy) == strings.ToLower(strings.TrimSpace(s)) {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("Unsupport match strategy:%s, must be one of %v", s, AllMatchStrategies)
+}
+
+// 参考golint中的常见缩写
+var commonInitialisms = map[string]bool{
+	"ACL":   true,
+	"API":   true,
+	"ASCII": true,
+	"CPU":   true,
+	"CSS":   true,
+	"DNS":   true,
+	"EOF":   true,
+	"GUID":  true,
+	"HTML":  true,
+	"HTTP":  true,
+	"HTTPS": true,
+	"ID":    true,
+	"IP":    true,
+	"JSON":  true,
+	"LHS":   true,
+	"QPS":   true,
+	"RAM":   true,
+	"RHS":   true,
+	"RPC":   true,
+	"SLA":   true,
+	"SMTP":  true,
+	"SQL":   true,
+	"SSH":   true,
+	"TCP":   true,
+	"TLS":   true,
+	"TTL":   true,
+	"UDP":   true,
+	"UI":    true,
+	"UID":   true,
+	"UUID":  true,
+	"URI":   true,
+	"URL":   true,
+	"UTF8":  true,
+	"VM":    true,
+	"XML":   true,
+	"XMPP":  true,
+	"XSRF":  true,
+	"XSS":   true,
+}
+
+// 为dst字段寻找赋值来源的src字段，字段名完全一致的优先
+func (f *GeneratorFactory) MatchField(src, dst *cst.Struct, dstField cst.Field) (cst.Field, bool) {
+	for _, srcField := range src.Fields {
+		if srcField.Name == dstField.Name {
+			return srcField, true
+		}
+	}
+
+	for _, srcField := range src.Fields {
+		if gen.IsBlankField(srcField) {
+			continue
+		}
+
+		var matched bool
+		switch f.opts.matchStrategy {
+		case MatchCaseInsensitive:
+			matched = strings.EqualFold(srcField.Name, dstField.Name)
+		case MatchInitialism:
+			matched = canonicalName(srcField.Name) == canonicalName(dstField.Name)
+		case MatchTag:
+			matched = f.wireName(src, srcField) == f.wireName(dst, dstField)
+		}
+		if matched {
+			return srcField, true
+		}
+	}
+	return cst.Field{}, false
+}
+
+// 字段序列化时使用的名称，没有声明时使用字段名
+func (f *GeneratorFactory) wireName(s *cst.Struct, field cst.Field) string {
+	tag := reflect.StructTag(field.Tag)
+	if f.pbcst != nil && s.PackageName == f.pbcst.PackageName() {
+		// protobuf:"bytes,1,opt,name=user_id,proto3"
+		for _, opt := range strings.Split(tag.Get("protobuf"), ",") {
+			if strings.HasPrefix(opt, "name=") {
+				return strings.TrimPrefix(opt, "name=")
+			}
+		}
+		// thrift:"user_id,1"
+		if name := strings.Split(tag.Get("thrift"), ",")[0]; name != "" {
+			return name
+		}
+		return field.Name
+	}
+
+	if pbTag, err := gen.ParsePBTag(field); err == nil && pbTag.Name != "" {
+		return pbTag.Name
+	}
+	return field.Name
+}
+
+// 按单词拆分字段名，常见缩写统一为大写，并去掉下划线
+// user_id => UserID, UserId => UserID, HttpURL => HTTPURL
+func canonicalName(name string) string {
+	var (
+		buff  = bytes.NewBufferString("")
+		runes = []rune(name)
+		start int
+	)
+	writeWord := func(word []rune) {
+		if len(word) == 0 {
+			return
+		}
+		s := string(word)
+		if u := strings.ToUpper(s); commonInitialisms[u] {
+			buff.WriteString(u)
+			return
+		}
+		buff.WriteRune(unicode.ToUpper(word[0]))
+		buff.WriteString(string(word[1:]))
+	}
+
+	for i, r := range runes {
+		if r == '_' {
+			writeWord(runes[start:i])
+			start = i + 1
+			continue
+		}
+		// 小写字母后跟大写字母是单词的边界
+		if i+1 == len(runes) || (unicode.IsLower(r) && unicode.IsUpper(runes[i+1])) {
+			writeWord(runes[start : i+1])
+			start = i + 1
+		}
+	}
+	return buff.String()
+}
+
+// 需要赋值的字段，跳过空白字段、未导出字段以及pb.go中的内部字段(XXX_sizecache等)
+func isAssignableField(field cst.Field) bool {
+	if gen.IsBlankField(field) || field.Name == "" {
+		return false
+	}
+	if strings.HasPrefix(field.Name, "XXX_") {
+		return false
+	}
+	return unicode.IsUpper([]rune(field.Name)[0])
+}
+
+// 记录没有找到赋值来源的字段 e.g. addpb.SumRequest.UserId (from addservice.SumRequest)
+func (f *GeneratorFactory) addUnmappedField(src, dst *cst.Struct, dstField cst.Field) {
+	f.unmappedFields[fmt.Sprintf("%s.%s (from %s)",
+		qualifiedTypeName(dst), dstField.Name, qualifiedTypeName(src))] = struct{}{}
+}
+
+// 记录在目标枚举中找不到同名成员的枚举值 e.g. addservice.PhoneType_WORK (to addpb.PhoneType)
+func (f *GeneratorFactory) addUnmappedMember(src, dst *cst.Struct, member string) {
+	f.unmappedFields[fmt.Sprintf("%s.%s (to %s)",
+		src.PackageName, member, qualifiedTypeName(dst))] = struct{}{}
+}
+
+// 生成过程中没有被赋值的目标字段及没有对应成员的枚举值，按名称排序
+func (f *GeneratorFactory) UnmappedFields() []string {
+	var fields []string
+	for field := range f.unmappedFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,139 @@
+package assignment
+
+import (
+	"fmt"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/utils"
+)
+
+// 整数类型的位数及是否有符号，int和uint按64位处理
+var integerTypes = map[string]struct {
+	bits   int
+	signed bool
+}{
+	"int":     {64, true},
+	"int8":    {8, true},
+	"int16":   {16, true},
+	"int32":   {32, true},
+	"rune":    {32, true},
+	"int64":   {64, true},
+	"uint":    {64, false},
+	"uint8":   {8, false},
+	"byte":    {8, false},
+	"uint16":  {16, false},
+	"uint32":  {32, false},
+	"uint64":  {64, false},
+	"uintptr": {64, false},
+}
+
+// 浮点数类型的位数
+var floatTypes = map[string]int{
+	"float32": 32,
+	"float64": 64,
+}
+
+// 数值之间的转换是否可能丢失数据
+// int64 => int32 超出范围, int => uint 负数, uint64 => int64 超出范围
+// float64 => int64 有小数部分或者超出范围, float64 => float32 超出范围
+// 整数转换为浮点数时只会损失精度，不视为收窄
+func IsNarrowing(srcType, dstType string) bool {
+	if srcBits, found := floatTypes[srcType]; found {
+		if _, found := integerTypes[dstType]; found {
+			return true
+		}
+		dstBits, found := floatTypes[dstType]
+		return found && dstBits < srcBits
+	}
+
+	src, srcFound := integerTypes[srcType]
+	dst, dstFound := integerTypes[dstType]
+	if !srcFound || !dstFound {
+		return false
+	}
+
+	switch {
+	case src.signed == dst.signed:
+		return dst.bits < src.bits
+	case src.signed:
+		return true
+	default:
+		return dst.bits <= src.bits
+	}
+}
+
+// 基础类型之间转换的表达式，value的类型为srcType
+// int => int64: int64(req.A)
+// int64 => int32: func() (r int32) { var e error; if r, e = int64ToInt32(req.A); e != nil && err == nil { err = e } ; return r }()
+func (f *GeneratorFactory) basicConvert(value, srcType, dstType string) string {
+	if srcType == dstType {
+		return value
+	}
+
+	if !f.opts.uncheckedNarrowing && IsNarrowing(srcType, dstType) {
+		f.probe.markFallible()
+		return fallibleCall(f.narrowingConverter(srcType, dstType), value, dstType)
+	}
+	return fmt.Sprintf("%s(%s)", dstType, value)
+}
+
+// 收窄时检查取值范围的转换方法，和结构体之间的转换方法一起输出到convert.go
+// func int64ToInt32(src int64) (int32, error)
+func (f *GeneratorFactory) narrowingConverter(srcType, dstType string) string {
+	name := srcType + "To" + utils.ToUpperFirst(dstType)
+	if _, found := f.converters[name]; found {
+		return name
+	}
+
+	var (
+		condition = fmt.Sprintf("%s(dst) != src", srcType)
+		message   = fmt.Sprintf("value %%d overflows %s", dstType)
+	)
+	switch _, srcFloat := floatTypes[srcType]; {
+	case srcFloat && floatTypes[dstType] > 0:
+		// 只检查是否溢出为无穷大，精度损失是浮点数之间转换的正常结果
+		condition = "math.IsInf(float64(dst), 0) && !math.IsInf(float64(src), 0)"
+		message = fmt.Sprintf("value %%v overflows %s", dstType)
+	case srcFloat:
+		// 转换回浮点数不相等时说明有小数部分、超出范围或者为NaN
+		message = fmt.Sprintf("value %%v can not be represented by %s", dstType)
+	default:
+		switch src, dst := integerTypes[srcType], integerTypes[dstType]; {
+		case src.signed && !dst.signed:
+			condition = "src < 0 || " + condition
+		case !src.signed && dst.signed:
+			condition = "dst < 0 || " + condition
+		}
+	}
+
+	buff := &strings.Builder{}
+	fmt.Fprintf(buff, "func %s(src %s) (%s, error) {\n", name, srcType, dstType)
+	fmt.Fprintf(buff, "dst := %s(src)\n", dstType)
+	fmt.Fprintf(buff, "if %s {\n", condition)
+	fmt.Fprintf(buff, "return 0, fmt.Errorf(%q, src)\n", message)
+	fmt.Fprintf(buff, "}\n")
+	fmt.Fprintf(buff, "return dst, nil\n")
+	fmt.Fprintf(buff, "}\n")
+
+	f.converters[name] = &converter{name: name, body: buff.String()}
+	return name
+}
+
+// 引用别名前需要检查的nil条件，star表示别名本身是否为指针
+// ObjectAlias只检查引用路径上的指针，SimpleAlias(数组及map的元素)不做检查
+// 这里保证对指针解引用之前总是检查其本身
+func checkNil(alias Alias, star bool) (statement string, isNeed bool) {
+	statement, isNeed = alias.CheckNil()
+	if !star {
+		return statement, isNeed
+	}
+
+	self := fmt.Sprintf(" %s != nil ", alias)
+	switch {
+	case !isNeed:
+		statement = self
+	case !strings.Contains(statement, self):
+		statement += "&&" + self
+	}
+	return statement, true
+}
@@ -0,0 +1,112 @@
+package assignment
+
+import gen "ezrpro.com/micro/kit/pkg/generator"
+
+// pbcst中的结构体由哪种IDL生成，决定了转换时可以使用的方法
+type Schema string
+
+const (
+	// pb.go: optional字段通过getter取值，通过proto.Int64等辅助方法赋值
+	SchemaProtobuf Schema = "protobuf"
+	// thrift生成的go代码: optional字段通过getter取值
+	SchemaThrift Schema = "thrift"
+	// 普通的结构体: 没有getter及辅助方法 e.g. 任意两个结构体之间的转换
+	SchemaPlain Schema = "plain"
+)
+
+const (
+	DefaultConverterPrefix = "pb"
+	DefaultMatchStrategy   = MatchExact
+	DefaultEnumUnknown     = EnumUnknownPassthrough
+	DefaultSchema          = SchemaProtobuf
+)
+
+type Options struct {
+	converterPrefix string               // 转换方法名中pb结构体的前缀 e.g. pbAddressToAddress
+	matchStrategy   MatchStrategy        // 字段匹配策略
+	enumUnknown     EnumUnknownBehaviour // 未定义枚举值的处理方式
+	typeConverters  gen.TypeConverters   // 自定义类型的转换方法
+	exported        bool                 // 转换方法是否导出
+	schema          Schema               // pbcst中的结构体由哪种IDL生成
+	// 数值收窄时不检查取值范围，直接截断
+	// 默认检查，此时存在收窄的转换方法返回error
+	uncheckedNarrowing bool
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.converterPrefix == "" {
+		options.converterPrefix = DefaultConverterPrefix
+	}
+
+	if options.matchStrategy == "" {
+		options.matchStrategy = DefaultMatchStrategy
+	}
+
+	if options.enumUnknown == "" {
+		options.enumUnknown = DefaultEnumUnknown
+	}
+
+	if options.schema == "" {
+		options.schema = DefaultSchema
+	}
+
+	options.typeConverters = options.typeConverters.WithBuiltin()
+	return options
+}
+
+// 同一个transport包中存在多个协议的转换方法时，通过前缀区分
+// e.g. pbAddressToAddress thriftAddressToAddress
+func WithConverterPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.converterPrefix = prefix
+	}
+}
+
+// 字段名不一致时的匹配策略 e.g. UserID => UserId
+func WithMatchStrategy(strategy MatchStrategy) Option {
+	return func(o *Options) {
+		o.matchStrategy = strategy
+	}
+}
+
+// 枚举转换时遇到未定义的枚举值的处理方式
+func WithEnumUnknown(behaviour EnumUnknownBehaviour) Option {
+	return func(o *Options) {
+		o.enumUnknown = behaviour
+	}
+}
+
+// 自定义类型与pb.go类型之间通过用户提供的方法转换 e.g. decimal.Decimal <=> string
+func WithTypeConverters(converters gen.TypeConverters) Option {
+	return func(o *Options) {
+		o.typeConverters = converters
+	}
+}
+
+// 转换方法输出到独立的包中时需要导出 e.g. UserToServiceUser
+func WithExported(exported bool) Option {
+	return func(o *Options) {
+		o.exported = exported
+	}
+}
+
+// 数值收窄时不检查取值范围 e.g. int64 => int32直接截断
+func WithUncheckedNarrowing(unchecked bool) Option {
+	return func(o *Options) {
+		o.uncheckedNarrowing = unchecked
+	}
+}
+
+// pbcst中的结构体由哪种IDL生成 e.g. thrift生成的结构体不能使用proto.Int64等辅助方法
+func WithSchema(schema Schema) Option {
+	return func(o *Options) {
+		o.schema = schema
+	}
+}
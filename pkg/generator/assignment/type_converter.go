@@ -0,0 +1,109 @@
+package assignment
+
+import (
+	"fmt"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+)
+
+// 调用配置的自定义类型转换方法，两端都不是自定义类型时返回false
+// 转换方法的参数和返回值不包含字段本身的指针，由生成的代码处理nil
+// Amount: convert.DecimalToString(req.Amount),
+// Amount: func() (v *decimal.Decimal) { if req.Amount != nil { k := convert.StringToDecimal(*req.Amount); v = &k } ; return v }(),
+func (g *AssignmentGenerator) generateTypeConverterCall(srcAlias Alias, srcType, dstType cst.Type) bool {
+	var (
+		converters             = g.factory.opts.typeConverters
+		srcConverter, srcFound = converters.Find(srcType.BaseType)
+		dstConverter, dstFound = converters.Find(dstType.BaseType)
+		srcStar, dstStar       = srcType.Star, dstType.Star
+		fn                     string
+		fallible               bool
+		aliasName              = srcAlias.String()
+		statement, isNeed      = srcAlias.CheckNil()
+	)
+
+	switch {
+	case srcFound && dstFound:
+		// 两端是同一个自定义类型，只需要处理指针
+		g.generateBasicTypeAssignmentConvertFunc(srcAlias, srcType.BaseType, dstType.BaseType)
+		return true
+	case srcFound:
+		fn, fallible = srcConverter.ToProto, srcConverter.ToProtoError
+		// proto类型为message时，转换方法直接返回指针
+		dstStar = dstStar && srcConverter.IsScalarProtoType()
+	case dstFound:
+		fn, fallible = dstConverter.FromProto, dstConverter.FromProtoError
+		srcStar = srcStar && dstConverter.IsScalarProtoType()
+	default:
+		return false
+	}
+	g.factory.useBuiltinConverter(fn)
+
+	value := aliasName
+	if srcStar {
+		value = "*" + aliasName
+		statement, isNeed = checkNil(srcAlias, true)
+	}
+
+	resultType := dstType.BaseType
+	if dstStar {
+		resultType.Star = false
+	}
+	call := fmt.Sprintf("%s(%s)", fn, value)
+	if fallible {
+		g.factory.probe.markFallible()
+		call = fallibleCall(fn, value, resultType.String())
+	}
+
+	switch {
+	case !isNeed && !dstStar:
+		g.print(" %s ", call)
+	case !isNeed && dstStar:
+		g.print("func() (v %s) { k := %s; v = &k ; return v }()", dstType.BaseType, call)
+	case dstStar:
+		g.print("func() (v %s) { if %s { k := %s; v = &k } ; return v }()", dstType.BaseType, statement, call)
+	default:
+		g.print("func() (v %s) { if %s { v = %s } ; return v }()", dstType.BaseType, statement, call)
+	}
+	return true
+}
+
+// 内置转换方法的实现，零值的time.Time和nil的Timestamp互相转换
+var builtinConverterBodies = map[string]string{
+	"timeToPbTimestamp": `func timeToPbTimestamp(src time.Time) *timestamppb.Timestamp {
+	if src.IsZero() {
+		return nil
+	}
+	return timestamppb.New(src)
+}
+`,
+	"pbTimestampToTime": `func pbTimestampToTime(src *timestamppb.Timestamp) time.Time {
+	if src == nil {
+		return time.Time{}
+	}
+	return src.AsTime()
+}
+`,
+	"durationToPbDuration": `func durationToPbDuration(src time.Duration) *durationpb.Duration {
+	return durationpb.New(src)
+}
+`,
+	"pbDurationToDuration": `func pbDurationToDuration(src *durationpb.Duration) time.Duration {
+	if src == nil {
+		return 0
+	}
+	return src.AsDuration()
+}
+`,
+}
+
+// 用到内置的转换方法时和结构体之间的转换方法一起输出到convert.go
+func (f *GeneratorFactory) useBuiltinConverter(name string) {
+	body, found := builtinConverterBodies[name]
+	if !found {
+		return
+	}
+	if _, found := f.converters[name]; !found {
+		f.converters[name] = &converter{name: name, body: body}
+	}
+}
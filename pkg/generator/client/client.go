@@ -45,7 +45,12 @@ func (g *ClientGenerator) Generate() error {
 			return err
 		}
 
-		err = t.Execute(readWriter.writer, map[string]interface{}{
+		envelopes, err := gen.NewEnvelopes(g.cst.PackageName(), serviceIface)
+		if err != nil {
+			return err
+		}
+
+		err = gen.ExecuteTemplate(t, readWriter.writer, map[string]interface{}{
 			"BaseServiceName":     g.opts.baseServiceName,
 			"PackageName":         g.opts.clientPackageName,
 			"ServiceName":         serviceIface.Name,
@@ -54,6 +59,10 @@ func (g *ClientGenerator) Generate() error {
 			"EndpointImportPath":  utils.GetEndpointImportPath(g.opts.baseServiceName),
 			"ProtobufImportPath":  utils.GetProtobufImportPath(g.opts.baseServiceName),
 			"TransportImportPath": utils.GetTransportImportPath(g.opts.baseServiceName),
+			// 非标准签名的方法保持原有的签名
+			"Envelopes": envelopes,
+			// 方法签名中引用的其他包，未使用的import由goimports移除
+			"ServiceImports": g.cst.Imports(),
 		})
 		if err != nil {
 			return err
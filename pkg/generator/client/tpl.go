@@ -7,9 +7,13 @@ var DefaultClientTemplate = `
 package {{.PackageName}}
 
 import (
+	"context"
 	"errors"
 	"io"
 	"time"
+	{{- range .ServiceImports}}
+	{{.Alias}} {{.Path}}
+	{{- end}}
 
 	{{$servicePackageName}} "{{.ServiceImportPath}}"
         {{$endpointPackageName}} "{{.EndpointImportPath}}"
@@ -26,6 +30,7 @@ import (
 func New(opts ...Option) ({{$servicePackageName}}.{{.ServiceName}}, error) {
 	var options Options
 	options = newOptions(opts...)
+	options.middlewareCreators = append([]middleware.Creator{policyMiddleware(options.policies)}, options.middlewareCreators...)
 
 	switch options.transport {
 	case spiderconn.TransportTypeGRPC:
@@ -62,6 +67,7 @@ func New(opts ...Option) ({{$servicePackageName}}.{{.ServiceName}}, error) {
 			options.instancer,
 			options.transport,
 			options.serviceName,
+			options.policies,
 			options.middlewareCreators,
 		)
 
@@ -87,12 +93,7 @@ func newHTTPClient(addr string, middlewareCreators []middleware.Creator) ({{$ser
 	)
 }
 
-func newSDClient(logger log.Logger, instancer sd.Instancer, transport, svcName string, middlewareCreators []middleware.Creator) ({{$servicePackageName}}.{{.ServiceName}}, error) {
-	var (
-		retryMax     = 3
-		retryTimeout = 500 * time.Millisecond
-	)
-
+func newSDClient(logger log.Logger, instancer sd.Instancer, transport, svcName string, policies {{$endpointPackageName}}.Policies, middlewareCreators []middleware.Creator) ({{$servicePackageName}}.{{.ServiceName}}, error) {
 	var (
 		endpoints  {{$endpointPackageName}}.Set
 		factoryFor func(makeEndpoint func({{$servicePackageName}}.{{.ServiceName}}) spiderconn.EndpointWrapper) sd.Factory
@@ -113,8 +114,7 @@ func newSDClient(logger log.Logger, instancer sd.Instancer, transport, svcName s
 	factory := factoryFor({{$endpointPackageName}}.Make{{$method.Name}}Endpoint)
 	endpointer := sd.NewEndpointer(instancer, factory, logger)
 	balancer := lb.NewRoundRobin(endpointer)
-	retry := lb.Retry(retryMax, retryTimeout, balancer)
-        {{ToLowerFirstCamelCase $method.Name}}Endpoint = retry
+        {{ToLowerFirstCamelCase $method.Name}}Endpoint = retryEndpoint(balancer, policies.Get(method))
 	for _, middlewareCreator := range middlewareCreators {
 		{{ToLowerFirstCamelCase $method.Name}}Endpoint = middlewareCreator(method)({{ToLowerFirstCamelCase $method.Name}}Endpoint)
 	}
@@ -124,6 +124,79 @@ func newSDClient(logger log.Logger, instancer sd.Instancer, transport, svcName s
 	return endpoints, nil
 }
 
+// policyMiddleware applies the timeout, rate limit and concurrency limit
+// of the policy of each method, including the retries.
+func policyMiddleware(policies {{$endpointPackageName}}.Policies) middleware.Creator {
+	return func(method string) endpoint.Middleware {
+		return policies.Get(method).ClientMiddleware()
+	}
+}
+
+// defaultRetryTimeout bounds the retries of the methods whose policy has
+// no timeout.
+const defaultRetryTimeout = 500 * time.Millisecond
+
+// retryEndpoint calls the instances of balancer and retries at most
+// policy.Retries times, waiting policy.Backoff before the first retry and
+// doubling it for each retry. Errors caused by the caller e.g. InvalidArgument
+// will not succeed by retrying, methods which are not idempotent are retried
+// only if the request has not reached the service.
+func retryEndpoint(balancer lb.Balancer, policy {{$endpointPackageName}}.Policy) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		if policy.Timeout <= 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, defaultRetryTimeout)
+			defer cancel()
+		}
+
+		backoff := policy.Backoff
+		for n := 0; ; n++ {
+			response, err := callBalancer(ctx, balancer, request)
+			if err == nil {
+				return response, nil
+			}
+			if n >= policy.Retries || !retryable(err) || (!policy.Idempotent && !unreached(err)) {
+				return nil, err
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+}
+
+func callBalancer(ctx context.Context, balancer lb.Balancer, request interface{}) (interface{}, error) {
+	e, err := balancer.Endpoint()
+	if err != nil {
+		return nil, err
+	}
+	return e(ctx, request)
+}
+
+// unreached reports whether the call failed before reaching the service.
+func unreached(err error) bool {
+	return err == lb.ErrNoEndpoints || {{$servicePackageName}}.ErrorCode(err) == {{$servicePackageName}}.CodeUnavailable
+}
+
+func retryable(err error) bool {
+	switch {{$servicePackageName}}.ErrorCode(err) {
+	case {{$servicePackageName}}.CodeInvalidArgument,
+		{{$servicePackageName}}.CodeNotFound,
+		{{$servicePackageName}}.CodeAlreadyExists,
+		{{$servicePackageName}}.CodePermissionDenied,
+		{{$servicePackageName}}.CodeFailedPrecondition,
+		{{$servicePackageName}}.CodeOutOfRange,
+		{{$servicePackageName}}.CodeUnimplemented,
+		{{$servicePackageName}}.CodeUnauthenticated:
+		return false
+	}
+	return true
+}
+
 func grpcFactoryFor(makeEndpoint func({{$servicePackageName}}.{{.ServiceName}}) spiderconn.EndpointWrapper) sd.Factory {
 	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
 		conn, err := grpc.Dial(instance, grpc.WithInsecure(), grpc.WithTimeout(time.Second))
@@ -150,9 +223,11 @@ func httpFactoryFor(makeEndpoint func({{$servicePackageName}}.{{.ServiceName}})
 `
 
 var DefaultOptionsTemplate = `
+{{$endpointPackageName := BasePath .EndpointImportPath}}
 package {{.PackageName}}
 
 import (
+	{{$endpointPackageName}} "{{.EndpointImportPath}}"
 	"ezrpro.com/micro/spiderconn"
 	"ezrpro.com/micro/spiderconn/middleware"
 	"github.com/go-kit/kit/log"
@@ -180,12 +255,17 @@ type Options struct {
 	grpcAddr string
 
 	middlewareCreators []middleware.Creator
+
+	// 各个方法的超时、重试、限流及并发限制策略
+	policies {{$endpointPackageName}}.Policies
 }
 
 type Option func(*Options)
 
 func newOptions(opts ...Option) Options {
-	var options Options
+	options := Options{
+		policies: {{$endpointPackageName}}.DefaultPolicies(),
+	}
 	for _, opt := range opts {
 		opt(&options)
 	}
@@ -249,6 +329,14 @@ func WithGrpcAddress(addr string) Option {
 	}
 }
 
+// WithPolicies replaces the policies of the methods, e.g. the policies
+// returned by {{$endpointPackageName}}.LoadPolicies.
+func WithPolicies(policies {{$endpointPackageName}}.Policies) Option {
+	return func(o *Options) {
+		o.policies = policies
+	}
+}
+
 func WithMiddlewareCreator(middlewareCreators ...middleware.Creator) Option {
 	return func(o *Options) {
 		o.middlewareCreators = append(o.middlewareCreators, middlewareCreators...)
@@ -299,9 +387,16 @@ func GetDefaultClient() {{$servicePackageName}}.{{.ServiceName}} {
 	return defaultClient
 }
 
-{{range $index, $method := .ServiceMethods}}
+{{range $index, $envelope := .Envelopes}}
+{{$method := $envelope.Method}}
+{{if $envelope.Standard}}
 func {{$method.Name}}(ctx context.Context, req *{{$servicePackageName}}.{{$method.Name}}Request) (resp *{{$servicePackageName}}.{{$method.Name}}Response, err error) {
 	return GetDefaultClient().{{$method.Name}}(ctx, req)
 }
+{{else}}
+func {{$method.Name}}({{$envelope.ParamList $servicePackageName}}) ({{$envelope.ResultList $servicePackageName}}) {
+	return GetDefaultClient().{{$method.Name}}({{$envelope.ParamNames}})
+}
+{{end}}
 {{end}}
 `
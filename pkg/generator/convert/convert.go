@@ -0,0 +1,125 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	gen "ezrpro.com/micro/kit/pkg/generator"
+	"ezrpro.com/micro/kit/pkg/generator/assignment"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultPackageName = "convert"
+)
+
+// 任意两个结构体之间转换方法的生成器 e.g. 数据库模型 <=> service中的结构体
+// 转换方法输出到独立的包中，并且导出 e.g. func UserToServiceUser(src *model.User) *service.User
+type ConvertGenerator struct {
+	src     cst.ConcreteSyntaxTree
+	dst     cst.ConcreteSyntaxTree
+	srcName string
+	dstName string
+	opts    Options
+}
+
+// src, dst: 源结构体和目标结构体所在文件的语法树
+// srcName, dstName: 结构体名 e.g. User
+func NewConvertGenerator(src, dst cst.ConcreteSyntaxTree, srcName, dstName string, opts ...Option) gen.Generator {
+	return &ConvertGenerator{
+		src:     src,
+		dst:     dst,
+		srcName: srcName,
+		dstName: dstName,
+		opts:    newOptions(opts...),
+	}
+}
+
+func (g *ConvertGenerator) Generate() error {
+	srcStruct, found := g.src.StructMap()[g.src.PackageName()][g.srcName]
+	if !found {
+		return fmt.Errorf("Not found struct(%s.%s)", g.src.PackageName(), g.srcName)
+	}
+
+	dstStruct, found := g.dst.StructMap()[g.dst.PackageName()][g.dstName]
+	if !found {
+		return fmt.Errorf("Not found struct(%s.%s)", g.dst.PackageName(), g.dstName)
+	}
+
+	// 生成的代码通过包名引用两端的结构体，不能输出到它们所在的包中
+	if g.opts.packageName == srcStruct.PackageName || g.opts.packageName == dstStruct.PackageName {
+		return fmt.Errorf("Package(%s) of converters must be different from %s and %s",
+			g.opts.packageName, srcStruct.PackageName, dstStruct.PackageName)
+	}
+
+	factory := assignment.NewGeneratorFactory(
+		g.src,
+		g.dst,
+		assignment.WithConverterPrefix(g.dst.PackageName()),
+		assignment.WithSchema(g.opts.schema),
+		assignment.WithExported(true),
+		assignment.WithMatchStrategy(g.opts.matchStrategy),
+		assignment.WithEnumUnknown(g.opts.enumUnknown),
+		assignment.WithTypeConverters(g.opts.typeConverters),
+		assignment.WithUncheckedNarrowing(g.opts.uncheckedNarrowing),
+	)
+
+	_, err := factory.GenerateConverter(srcStruct, dstStruct)
+	if err != nil {
+		return err
+	}
+
+	if g.opts.both {
+		_, err = factory.GenerateConverter(dstStruct, srcStruct)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 目标字段没有被赋值或者枚举值没有对应的成员，意味着转换时会丢失数据
+	if fields := factory.UnmappedFields(); len(fields) > 0 {
+		msg := "The following fields or enum members have no mapping:\n\t" + strings.Join(fields, "\n\t")
+		if g.opts.strict {
+			return errors.New(msg)
+		}
+		logrus.Warn(msg)
+	}
+
+	tplBody, err := ioutil.ReadAll(g.opts.template)
+	if err != nil {
+		return err
+	}
+
+	t, err := template.New("convert").Parse(string(tplBody))
+	if err != nil {
+		return err
+	}
+
+	return gen.ExecuteTemplate(t, g.opts.writer, map[string]interface{}{
+		"PackageName": g.opts.packageName,
+		"Imports":     uniqueImports(g.opts.imports, g.opts.typeConverters.WithBuiltin().Imports()),
+		"Converters":  factory.Converters(),
+	})
+}
+
+// 两端结构体在同一个包中时只需要引入一次，未使用的import由goimports移除
+func uniqueImports(importsList ...[]string) []string {
+	var (
+		imports []string
+		exists  = map[string]struct{}{}
+	)
+	for _, list := range importsList {
+		for _, imp := range list {
+			if _, found := exists[imp]; found {
+				continue
+			}
+			exists[imp] = struct{}{}
+			imports = append(imports, imp)
+		}
+	}
+	return imports
+}
@@ -0,0 +1,123 @@
+package convert
+
+import (
+	"io"
+	"strings"
+
+	gen "ezrpro.com/micro/kit/pkg/generator"
+	"ezrpro.com/micro/kit/pkg/generator/assignment"
+)
+
+type Options struct {
+	template       io.Reader
+	writer         io.Writer
+	packageName    string   // 转换方法输出的包名
+	imports        []string // 源结构体和目标结构体所在包的import路径
+	both           bool     // 同时生成反向的转换方法
+	matchStrategy  assignment.MatchStrategy
+	enumUnknown    assignment.EnumUnknownBehaviour
+	typeConverters gen.TypeConverters
+	strict         bool              // 存在没有被赋值的字段时生成失败
+	schema         assignment.Schema // 目标结构体由哪种IDL生成
+	// 数值收窄时不检查取值范围
+	uncheckedNarrowing bool
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.template == nil {
+		options.template = strings.NewReader(DefaultConvertTemplate)
+	}
+
+	if options.writer == nil {
+		options.writer = gen.DefaultWriter
+	}
+
+	if options.packageName == "" {
+		options.packageName = DefaultPackageName
+	}
+
+	if options.schema == "" {
+		options.schema = assignment.SchemaPlain
+	}
+	return options
+}
+
+func WithTemplate(tpl io.Reader) Option {
+	return func(o *Options) {
+		o.template = tpl
+	}
+}
+
+func WithWriter(w io.Writer) Option {
+	return func(o *Options) {
+		o.writer = w
+	}
+}
+
+func WithPackageName(packageName string) Option {
+	return func(o *Options) {
+		o.packageName = packageName
+	}
+}
+
+// 源结构体和目标结构体所在包的import路径 e.g. ezrpro.com/micro/demo/pkg/model
+func WithImports(imports ...string) Option {
+	return func(o *Options) {
+		o.imports = append(o.imports, imports...)
+	}
+}
+
+// 同时生成目标结构体到源结构体的转换方法
+func WithBoth(both bool) Option {
+	return func(o *Options) {
+		o.both = both
+	}
+}
+
+// 字段名不一致时的匹配策略 e.g. UserID => UserId
+func WithMatchStrategy(strategy assignment.MatchStrategy) Option {
+	return func(o *Options) {
+		o.matchStrategy = strategy
+	}
+}
+
+// 枚举转换时遇到未定义的枚举值的处理方式
+func WithEnumUnknown(behaviour assignment.EnumUnknownBehaviour) Option {
+	return func(o *Options) {
+		o.enumUnknown = behaviour
+	}
+}
+
+// 自定义类型的转换方法 e.g. decimal.Decimal <=> string
+func WithTypeConverters(converters gen.TypeConverters) Option {
+	return func(o *Options) {
+		o.typeConverters = converters
+	}
+}
+
+func WithStrict(strict bool) Option {
+	return func(o *Options) {
+		o.strict = strict
+	}
+}
+
+// 目标结构体由IDL生成时可以使用getter及proto.Int64等辅助方法，默认为普通的结构体
+func WithSchema(schema assignment.Schema) Option {
+	return func(o *Options) {
+		o.schema = schema
+	}
+}
+
+// 数值收窄时不检查取值范围 e.g. int64 => int32直接截断
+func WithUncheckedNarrowing(unchecked bool) Option {
+	return func(o *Options) {
+		o.uncheckedNarrowing = unchecked
+	}
+}
@@ -0,0 +1,13 @@
+package convert
+
+var DefaultConvertTemplate = `
+package {{.PackageName}}
+
+import (
+	{{- range .Imports}}
+	"{{.}}"
+	{{- end}}
+)
+
+{{.Converters}}
+`
@@ -46,6 +46,9 @@ func (g *EndpointGenerator) Generate() error {
 		t := template.New(string(tplName)).Funcs(map[string]interface{}{
 			"ToLowerFirstCamelCase": utils.ToLowerFirstCamelCase,
 			"BasePath":              filepath.Base,
+			"TypeString":            gen.TypeString,
+			"HasEmailValidation":    gen.HasEmailValidation,
+			"DurationLiteral":       gen.DurationLiteral,
 		})
 		t, err = t.Parse(string(tplBody))
 		if err != nil {
@@ -57,11 +60,28 @@ func (g *EndpointGenerator) Generate() error {
 			return err
 		}
 
-		err = t.Execute(readWriter.writer, map[string]interface{}{
-			"PackageName":       g.opts.endpointPackageName,
-			"ServiceName":       serviceIface.Name,
-			"ServiceMethods":    serviceIface.Methods,
-			"ServiceImportPath": utils.GetServiceImportPath(g.opts.baseServiceName),
+		// 非标准签名的方法在endpoint中完成参数及返回值的装箱和拆箱
+		envelopes, err := gen.NewEnvelopes(g.cst.PackageName(), serviceIface)
+		if err != nil {
+			return err
+		}
+
+		// validate tag声明的校验规则
+		validations, err := gen.NewValidations(g.cst)
+		if err != nil {
+			return err
+		}
+
+		err = gen.ExecuteTemplate(t, readWriter.writer, map[string]interface{}{
+			"PackageName":        g.opts.endpointPackageName,
+			"ServiceName":        serviceIface.Name,
+			"ServiceMethods":     serviceIface.Methods,
+			"ServiceImportPath":  utils.GetServiceImportPath(g.opts.baseServiceName),
+			"ServicePackageName": g.cst.PackageName(),
+			// 方法签名中引用的其他包，未使用的import由goimports移除
+			"ServiceImports": g.cst.Imports(),
+			"Envelopes":      envelopes,
+			"Validations":    validations,
 		})
 		if err != nil {
 			return err
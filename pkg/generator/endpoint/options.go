@@ -11,13 +11,25 @@ const (
 	EndpointTemplate   Template = "endpoint"
 	OptionsTemplate    Template = "options"
 	MiddlewareTemplate Template = "middleware"
+	// 非标准签名方法的请求及响应结构体，输出到service所在的包中
+	EnvelopeTemplate Template = "envelope"
+	// 各个方法的请求数、错误数及耗时
+	InstrumentingTemplate Template = "instrumenting"
+	// 请求结构体的Validate方法，输出到service所在的包中
+	ValidateTemplate Template = "validate"
+	// 各个方法的超时、重试、限流及并发限制策略
+	PolicyTemplate Template = "policy"
 )
 
 var (
 	TemplateMap = map[Template]string{
-		EndpointTemplate:   DefaultEndpointTemplate,
-		OptionsTemplate:    DefaultOptionsTemplate,
-		MiddlewareTemplate: DefaultMiddlewareTemplate,
+		EndpointTemplate:      DefaultEndpointTemplate,
+		OptionsTemplate:       DefaultOptionsTemplate,
+		MiddlewareTemplate:    DefaultMiddlewareTemplate,
+		EnvelopeTemplate:      DefaultEnvelopeTemplate,
+		InstrumentingTemplate: DefaultInstrumentingTemplate,
+		ValidateTemplate:      DefaultValidateTemplate,
+		PolicyTemplate:        DefaultPolicyTemplate,
 	}
 )
 
@@ -7,6 +7,9 @@ package {{.PackageName}}
 
 import (
 	"context"
+	{{- range .ServiceImports}}
+	{{.Alias}} {{.Path}}
+	{{- end}}
 
 	"ezrpro.com/micro/spiderconn"
         {{$servicePackageName}} "{{.ServiceImportPath}}"
@@ -30,6 +33,8 @@ func New(opts ...Option) *Set {
 	var {{ToLowerFirstCamelCase $method.Name}} spiderconn.EndpointWrapper
 	{
 		{{ToLowerFirstCamelCase $method.Name}} = Make{{$method.Name}}Endpoint(options.service)
+		{{ToLowerFirstCamelCase $method.Name}}.Wrapper(ValidatingMiddleware())
+		{{ToLowerFirstCamelCase $method.Name}}.Wrapper(options.policies.Get("{{$method.Name}}").ServerMiddleware())
 		for _, middlewareCreator := range options.middlewareCreators {
 			{{ToLowerFirstCamelCase $method.Name}}.Wrapper(middlewareCreator({{ToLowerFirstCamelCase $method.Name}}.Name()))
 		}
@@ -43,7 +48,9 @@ func New(opts ...Option) *Set {
 	}
 }
 
-{{range $index, $method := .ServiceMethods}}
+{{range $index, $envelope := .Envelopes}}
+{{$method := $envelope.Method}}
+{{if $envelope.Standard}}
 // {{$method.Name}} implements the service interface, so Set may be used as a service.
 // This is primarily useful in the context of a client library.
 func (s Set) {{$method.Name}}(ctx context.Context, req *{{$servicePackageName}}.{{$method.Name}}Request) (resp *{{$servicePackageName}}.{{$method.Name}}Response, err error) {
@@ -62,6 +69,47 @@ func Make{{$method.Name}}Endpoint(s {{$servicePackageName}}.{{$serviceName}}) sp
 		return s.{{$method.Name}}(ctx, req)
 	})
 }
+{{else}}
+// {{$method.Name}} implements the service interface, so Set may be used as a service.
+// The arguments are packed into {{$envelope.Request.Name}} and the results are
+// unpacked from {{$envelope.Response.Name}}.
+func (s Set) {{$method.Name}}({{$envelope.ParamList $servicePackageName}}) ({{$envelope.ResultList $servicePackageName}}) {
+	{{- if not $envelope.HasContext}}
+	ctx := context.Background()
+	{{- end}}
+	temp, err := s.{{$method.Name}}Endpoint.Do(ctx, &{{$servicePackageName}}.{{$envelope.Request.Name}}{
+		{{- range $envelope.Params}}
+		{{.Field}}: {{.Name}},
+		{{- end}}
+	})
+	if err != nil {
+		return
+	}
+	{{- if $envelope.Results}}
+	response := temp.(*{{$servicePackageName}}.{{$envelope.Response.Name}})
+	return {{$envelope.Returns "response"}}
+	{{- else}}
+	_ = temp
+	return nil
+	{{- end}}
+}
+
+// Make{{$method.Name}}Endpoint constructs a {{$method.Name}} endpoint wrapping the service,
+// which unpacks the arguments from {{$envelope.Request.Name}} and packs the results into {{$envelope.Response.Name}}.
+func Make{{$method.Name}}Endpoint(s {{$servicePackageName}}.{{$serviceName}}) spiderconn.EndpointWrapper {
+	return spiderconn.NewWrapper("{{$method.Name}}", func(ctx context.Context, request interface{}) (resp interface{}, err error) {
+		{{- if $envelope.Params}}
+		req := request.(*{{$servicePackageName}}.{{$envelope.Request.Name}})
+		{{- end}}
+		response := &{{$servicePackageName}}.{{$envelope.Response.Name}}{}
+		{{$envelope.Assign "response"}}s.{{$method.Name}}({{$envelope.Args "req"}})
+		if err != nil {
+			return nil, err
+		}
+		return response, nil
+	})
+}
+{{end}}
 {{end}}
 `
 
@@ -78,12 +126,15 @@ type Options struct {
 	middlewareCreators []middleware.Creator
 	service            {{$servicePackageName}}.{{.ServiceName}}
 	serviceOptions     []{{$servicePackageName}}.Option
+	policies           Policies
 }
 
 type Option func(*Options)
 
 func newOptions(opts ...Option) Options {
-	var options Options
+	options := Options{
+		policies: DefaultPolicies(),
+	}
 	for _, opt := range opts {
 		opt(&options)
 	}
@@ -112,9 +163,37 @@ func WithServiceOptions(opts ...{{$servicePackageName}}.Option) Option {
 		o.serviceOptions = append(o.serviceOptions, opts...)
 	}
 }
+
+// WithPolicies replaces the policies of the methods, e.g. the policies
+// returned by LoadPolicies.
+func WithPolicies(policies Policies) Option {
+	return func(o *Options) {
+		o.policies = policies
+	}
+}
+
+// WithPolicy sets the policy of method.
+func WithPolicy(method string, policy Policy) Option {
+	return func(o *Options) {
+		policies := make(Policies, len(o.policies)+1)
+		for name, p := range o.policies {
+			policies[name] = p
+		}
+		policies[method] = policy
+		o.policies = policies
+	}
+}
+
+// WithMetrics records the request count, error count and latency of each method.
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *Options) {
+		o.middlewareCreators = append(o.middlewareCreators, metrics.Middleware)
+	}
+}
 `
 
 var DefaultMiddlewareTemplate = `
+{{$servicePackageName := BasePath .ServiceImportPath}}
 package {{.PackageName}}
 
 import (
@@ -122,6 +201,7 @@ import (
 	"fmt"
 	"time"
 
+	{{$servicePackageName}} "{{.ServiceImportPath}}"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
@@ -144,6 +224,37 @@ func InstrumentingMiddleware(duration metrics.Histogram) endpoint.Middleware {
 	}
 }
 
+// ValidatingMiddleware returns an endpoint middleware that validates requests
+// implementing Validate() error before the service is called, errors without
+// code are returned as InvalidArgument.
+func ValidatingMiddleware() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if v, ok := request.(interface{ Validate() error }); ok {
+				if err := v.Validate(); err != nil {
+					if {{$servicePackageName}}.ErrorCode(err) == {{$servicePackageName}}.CodeUnknown {
+						err = {{$servicePackageName}}.NewInvalidArgument("%v", err)
+					}
+					return nil, err
+				}
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// TimeoutMiddleware returns an endpoint middleware that cancels the context
+// of the invocation after timeout.
+func TimeoutMiddleware(timeout time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next(ctx, request)
+		}
+	}
+}
+
 // LoggingMiddleware returns an endpoint middleware that logs the
 // duration of each invocation, and the resulting error, if any.
 func LoggingMiddleware(logger log.Logger) endpoint.Middleware {
@@ -159,3 +270,393 @@ func LoggingMiddleware(logger log.Logger) endpoint.Middleware {
 	}
 }
 `
+
+var DefaultEnvelopeTemplate = `
+package {{.ServicePackageName}}
+
+import (
+	{{range .ServiceImports}}
+	{{.Alias}} {{.Path}}
+	{{- end}}
+)
+
+{{range .Envelopes}}
+{{if not .Standard}}
+// {{.Request.Name}} collects the arguments of {{.Method.Name}}, it is used to
+// transport the arguments between the endpoint and the transports.
+type {{.Request.Name}} struct {
+	{{range .Params}}{{.Field}} {{TypeString .Type ""}}
+	{{end}}
+}
+
+// {{.Response.Name}} collects the results of {{.Method.Name}}.
+type {{.Response.Name}} struct {
+	{{range .Results}}{{.Field}} {{TypeString .Type ""}}
+	{{end}}
+}
+{{end}}
+{{end}}
+`
+
+var DefaultInstrumentingTemplate = `
+{{$servicePackageName := BasePath .ServiceImportPath}}
+package {{.PackageName}}
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	{{$servicePackageName}} "{{.ServiceImportPath}}"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// Methods are the methods of {{.ServiceName}} recorded by Metrics.
+var Methods = []string{
+{{- range .ServiceMethods}}
+	"{{.Name}}",
+{{- end}}
+}
+
+// Metrics records the request count, error count and latency of each method.
+type Metrics struct {
+	requests metrics.Counter
+	errors   metrics.Counter
+	duration metrics.Histogram
+}
+
+// NewMetrics returns Metrics backed by Prometheus, the collectors are
+// registered to the default Prometheus registerer, so it should be called once.
+func NewMetrics(namespace, subsystem string) *Metrics {
+	m := &Metrics{
+		requests: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Total number of requests received.",
+		}, []string{"method"}),
+		errors: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_errors_total",
+			Help:      "Total number of requests failed.",
+		}, []string{"method", "code"}),
+		duration: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Request duration in seconds.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, []string{"method", "success"}),
+	}
+
+	// Methods without requests are also exported.
+	for _, method := range Methods {
+		m.requests.With("method", method).Add(0)
+	}
+	return m
+}
+
+// Middleware returns an endpoint middleware recording the metrics of the method,
+// it is a middleware.Creator so it can be applied by WithMetrics.
+func (m *Metrics) Middleware(method string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			defer func(begin time.Time) {
+				failed := err
+				if f, ok := response.(endpoint.Failer); ok && failed == nil {
+					failed = f.Failed()
+				}
+
+				m.requests.With("method", method).Add(1)
+				if failed != nil {
+					m.errors.With("method", method, "code", {{$servicePackageName}}.ErrorCode(failed).String()).Add(1)
+				}
+				m.duration.With("method", method, "success", fmt.Sprint(failed == nil)).Observe(time.Since(begin).Seconds())
+			}(time.Now())
+			return next(ctx, request)
+		}
+	}
+}
+`
+
+var DefaultValidateTemplate = `
+package {{.ServicePackageName}}
+
+import (
+	"net/mail"
+	"unicode/utf8"
+)
+
+{{range .Validations}}
+// Validate checks the fields of {{.Name}} by the validate tags.
+func (r *{{.Name}}) Validate() error {
+	if r == nil {
+		return NewInvalidArgument("{{.Name}} is required")
+	}
+	{{range .Checks}}
+	{{.}}
+	{{end}}
+	return nil
+}
+{{end}}
+
+{{if HasEmailValidation .Validations}}
+func validateEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+{{end}}
+`
+
+var DefaultPolicyTemplate = `
+{{$servicePackageName := BasePath .ServiceImportPath}}
+package {{.PackageName}}
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	{{$servicePackageName}} "{{.ServiceImportPath}}"
+	"github.com/go-kit/kit/endpoint"
+	"golang.org/x/time/rate"
+)
+
+// Policy controls the calls of a method, zero values mean no limit.
+type Policy struct {
+	// Timeout is the deadline of a call, including the retries of clients.
+	Timeout time.Duration
+	// Retries is the max number of retries of clients after the first attempt fails.
+	Retries int
+	// Backoff is the delay before the first retry, it doubles for each retry.
+	Backoff time.Duration
+	// Idempotent methods are retried on any retryable error, the others are
+	// retried only if the request has not reached the service.
+	Idempotent bool
+	// RateLimit is the number of calls allowed per second, Burst is the number
+	// of calls allowed at once.
+	RateLimit float64
+	Burst     int
+	// MaxConcurrent is the number of calls allowed in progress.
+	MaxConcurrent int
+}
+
+// DefaultPolicy is the policy of the methods without kit: directives.
+var DefaultPolicy = Policy{Retries: 2}
+
+// Policies are the policies of the methods by name.
+type Policies map[string]Policy
+
+// DefaultPolicies returns DefaultPolicy for each method, overridden by
+// kit:timeout and kit:idempotent.
+func DefaultPolicies() Policies {
+	policies := Policies{}
+	for _, method := range Methods {
+		policies[method] = DefaultPolicy
+	}
+{{- range .ServiceMethods}}
+{{- if or .Directives.Timeout .Directives.Idempotent}}
+	{{- $name := ToLowerFirstCamelCase .Name}}
+
+	{{$name}} := DefaultPolicy
+	{{- if .Directives.Timeout}}
+	{{$name}}.Timeout = {{DurationLiteral .Directives.Timeout}} // kit:timeout {{.Directives.Timeout}}
+	{{- end}}
+	{{- if .Directives.Idempotent}}
+	{{$name}}.Idempotent = true // kit:idempotent
+	{{- end}}
+	policies["{{.Name}}"] = {{$name}}
+{{- end}}
+{{- end}}
+	return policies
+}
+
+// Get returns the policy of method, DefaultPolicy if not found.
+func (p Policies) Get(method string) Policy {
+	if policy, found := p[method]; found {
+		return policy
+	}
+	return DefaultPolicy
+}
+
+// LoadPolicies reads the policies from a JSON config file, the fields present
+// in the file override DefaultPolicies, "*" applies to all methods, e.g.
+//
+//	{
+//		"*": {"retries": 1, "max_concurrent": 100},
+//		"GetUser": {"timeout": "2s", "backoff": "100ms", "rate_limit": 50, "burst": 10}
+//	}
+func LoadPolicies(filename string) (Policies, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs map[string]json.RawMessage
+	if err := json.Unmarshal(b, &configs); err != nil {
+		return nil, fmt.Errorf("policies %s: %v", filename, err)
+	}
+
+	policies := DefaultPolicies()
+	if config, found := configs["*"]; found {
+		for method, policy := range policies {
+			if err := json.Unmarshal(config, &policy); err != nil {
+				return nil, fmt.Errorf("policies %s: *: %v", filename, err)
+			}
+			policies[method] = policy
+		}
+	}
+	for method, config := range configs {
+		if method == "*" {
+			continue
+		}
+		policy, found := policies[method]
+		if !found {
+			return nil, fmt.Errorf("policies %s: unknown method %s", filename, method)
+		}
+		if err := json.Unmarshal(config, &policy); err != nil {
+			return nil, fmt.Errorf("policies %s: %s: %v", filename, method, err)
+		}
+		policies[method] = policy
+	}
+	return policies, nil
+}
+
+// UnmarshalJSON sets the fields present in data only, durations are strings
+// such as "1.5s".
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	var config struct {
+		Timeout       *duration ` + "`" + `json:"timeout"` + "`" + `
+		Retries       *int      ` + "`" + `json:"retries"` + "`" + `
+		Backoff       *duration ` + "`" + `json:"backoff"` + "`" + `
+		Idempotent    *bool     ` + "`" + `json:"idempotent"` + "`" + `
+		RateLimit     *float64  ` + "`" + `json:"rate_limit"` + "`" + `
+		Burst         *int      ` + "`" + `json:"burst"` + "`" + `
+		MaxConcurrent *int      ` + "`" + `json:"max_concurrent"` + "`" + `
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	if config.Timeout != nil {
+		p.Timeout = time.Duration(*config.Timeout)
+	}
+	if config.Retries != nil {
+		p.Retries = *config.Retries
+	}
+	if config.Backoff != nil {
+		p.Backoff = time.Duration(*config.Backoff)
+	}
+	if config.Idempotent != nil {
+		p.Idempotent = *config.Idempotent
+	}
+	if config.RateLimit != nil {
+		p.RateLimit = *config.RateLimit
+	}
+	if config.Burst != nil {
+		p.Burst = *config.Burst
+	}
+	if config.MaxConcurrent != nil {
+		p.MaxConcurrent = *config.MaxConcurrent
+	}
+	return nil
+}
+
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1.5s\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// ServerMiddleware returns an endpoint middleware applying the timeout, rate
+// limit and concurrency limit of the policy, calls over the limits are rejected
+// with ResourceExhausted. The limits are shared by the calls of the returned
+// middleware, so it should be created once for each method.
+func (p Policy) ServerMiddleware() endpoint.Middleware {
+	return p.middleware(false)
+}
+
+// ClientMiddleware returns an endpoint middleware applying the timeout, rate
+// limit and concurrency limit of the policy, calls over the limits wait until
+// they are allowed or the context is done.
+func (p Policy) ClientMiddleware() endpoint.Middleware {
+	return p.middleware(true)
+}
+
+func (p Policy) middleware(wait bool) endpoint.Middleware {
+	var limiter *rate.Limiter
+	if p.RateLimit > 0 {
+		burst := p.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(p.RateLimit), burst)
+	}
+
+	var slots chan struct{}
+	if p.MaxConcurrent > 0 {
+		slots = make(chan struct{}, p.MaxConcurrent)
+	}
+
+	limit := func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if limiter != nil {
+				if !wait && !limiter.Allow() {
+					return nil, {{$servicePackageName}}.NewResourceExhausted("rate limit exceeded")
+				}
+				if wait {
+					if err := limiter.Wait(ctx); err != nil {
+						if ctx.Err() != nil {
+							return nil, {{$servicePackageName}}.AsError(ctx.Err())
+						}
+						return nil, {{$servicePackageName}}.NewResourceExhausted("rate limit exceeded: %v", err)
+					}
+				}
+			}
+
+			if slots != nil {
+				if wait {
+					select {
+					case slots <- struct{}{}:
+					case <-ctx.Done():
+						return nil, {{$servicePackageName}}.AsError(ctx.Err())
+					}
+				} else {
+					select {
+					case slots <- struct{}{}:
+					default:
+						return nil, {{$servicePackageName}}.NewResourceExhausted("concurrency limit exceeded")
+					}
+				}
+				defer func() { <-slots }()
+			}
+
+			return next(ctx, request)
+		}
+	}
+	if p.Timeout <= 0 {
+		return limit
+	}
+
+	// The time waiting for the limits counts towards the timeout.
+	timeout := TimeoutMiddleware(p.Timeout)
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return timeout(limit(next))
+	}
+}
+`
@@ -0,0 +1,393 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	"ezrpro.com/micro/kit/pkg/utils"
+)
+
+// 方法的请求及响应结构体(信封)
+// 标准签名的方法直接使用已定义的结构体:
+// Method(ctx context.Context, req *MethodRequest) (resp *MethodResponse, err error)
+// 其他签名的方法由参数及返回值合成结构体，endpoint中负责装箱及拆箱
+// e.g. GetUser(ctx context.Context, id int64) (User, error) =>
+// type GetUserRequest struct { Id int64 }
+// type GetUserResponse struct { Result User }
+type Envelope struct {
+	Method     cst.Method
+	Standard   bool            // 标准签名，不需要合成结构体
+	HasContext bool            // 第一个参数为context.Context
+	Params     []EnvelopeField // 不包含context的参数
+	Results    []EnvelopeField // 不包含error的返回值
+	Request    *cst.Struct
+	Response   *cst.Struct
+}
+
+// 参数或返回值与结构体字段的对应关系
+type EnvelopeField struct {
+	Name     string // 参数名，匿名参数使用argN e.g. id
+	Field    string // 结构体中的字段名 e.g. Id
+	Type     cst.Type
+	Variadic bool // 可变参数，结构体中为切片 e.g. ids ...int64 => Ids []int64
+}
+
+// endpoint中已经使用的变量名，参数同名时重新命名
+var reservedParamNames = map[string]struct{}{
+	"s": {}, "ctx": {}, "req": {}, "resp": {}, "temp": {}, "response": {}, "err": {}, "_": {},
+}
+
+func NewEnvelope(packageName string, method cst.Method) (Envelope, error) {
+	e := Envelope{Method: method}
+	if IsStandardMethod(method) {
+		e.Standard = true
+		e.HasContext = true
+		return e, nil
+	}
+
+	// 使用了请求或响应结构体但签名不标准 e.g. Sum(ctx, req SumRequest) (SumResponse, error)
+	// 合成的结构体会与之重名，需要提示标准签名
+	requestName, responseName := method.Name+utils.GetRequestSuffix(), method.Name+utils.GetResponseSuffix()
+	for _, field := range append(append([]cst.Field{}, method.Params...), method.Results...) {
+		if field.Type.X == "" && (field.Type.Name == requestName || field.Type.Name == responseName) {
+			return e, fmt.Errorf(
+				"Method(%s) must be declared as %s(ctx context.Context, req *%s) (*%s, error)",
+				method.Name, method.Name, requestName, responseName,
+			)
+		}
+	}
+
+	params := method.Params
+	if len(params) > 0 && isContextType(params[0].Type) {
+		e.HasContext = true
+		params = params[1:]
+	}
+
+	// 传输层的错误需要通过error返回，不允许没有error返回值的方法
+	results := method.Results
+	if len(results) == 0 || !isErrorType(results[len(results)-1].Type) {
+		return e, fmt.Errorf("Method(%s) must return error as the last result", method.Name)
+	}
+	results = results[:len(results)-1]
+
+	e.Request = &cst.Struct{PackageName: packageName, Name: method.Name + utils.GetRequestSuffix()}
+	for i, param := range params {
+		if param.Type.GoType == cst.FuncType {
+			return e, fmt.Errorf("Method(%s) has unsupported func type parameter", method.Name)
+		}
+
+		field := EnvelopeField{Name: param.Name, Type: param.Type}
+		if _, found := reservedParamNames[field.Name]; found || field.Name == "" {
+			field.Name = fmt.Sprintf("arg%d", i)
+		}
+		field.Field = utils.ToCamelCase(field.Name)
+		if param.Type.GoType == cst.EllipsisType {
+			field.Variadic = true
+			field.Type = variadicToSlice(param.Type)
+		}
+		e.Params = append(e.Params, field)
+		e.Request.Fields = append(e.Request.Fields, cst.Field{Name: field.Field, Type: field.Type})
+	}
+
+	e.Response = &cst.Struct{PackageName: packageName, Name: method.Name + utils.GetResponseSuffix()}
+	for i, result := range results {
+		if result.Type.GoType == cst.FuncType {
+			return e, fmt.Errorf("Method(%s) has unsupported func type result", method.Name)
+		}
+
+		field := EnvelopeField{Name: result.Name, Type: result.Type}
+		switch {
+		case field.Name != "" && field.Name != "_":
+			field.Field = utils.ToCamelCase(field.Name)
+		case len(results) == 1:
+			field.Field = "Result"
+		default:
+			field.Field = fmt.Sprintf("Result%d", i)
+		}
+		field.Name = fmt.Sprintf("r%d", i)
+		e.Results = append(e.Results, field)
+		e.Response.Fields = append(e.Response.Fields, cst.Field{Name: field.Field, Type: field.Type})
+	}
+	return e, nil
+}
+
+// 接口中所有方法的信封
+func NewEnvelopes(packageName string, iface cst.Interface) ([]Envelope, error) {
+	var envelopes []Envelope
+	for _, method := range iface.Methods {
+		e, err := NewEnvelope(packageName, method)
+		if err != nil {
+			return nil, err
+		}
+		envelopes = append(envelopes, e)
+	}
+	return envelopes, nil
+}
+
+// 是否存在需要合成结构体的方法
+func HasSynthesizedEnvelope(envelopes []Envelope) bool {
+	for _, e := range envelopes {
+		if !e.Standard {
+			return true
+		}
+	}
+	return false
+}
+
+// Method(ctx context.Context, req *MethodRequest) (resp *MethodResponse, err error)
+func IsStandardMethod(method cst.Method) bool {
+	if len(method.Params) != 2 || len(method.Results) != 2 {
+		return false
+	}
+	req, resp := method.Params[1].Type, method.Results[0].Type
+	return isContextType(method.Params[0].Type) &&
+		req.Star && req.GoType == cst.StructType && req.X == "" &&
+		req.Name == method.Name+utils.GetRequestSuffix() &&
+		resp.Star && resp.GoType == cst.StructType && resp.X == "" &&
+		resp.Name == method.Name+utils.GetResponseSuffix() &&
+		isErrorType(method.Results[1].Type)
+}
+
+func isContextType(t cst.Type) bool {
+	return !t.Star && t.X == "context" && t.Name == "Context"
+}
+
+func isErrorType(t cst.Type) bool {
+	return !t.Star && t.X == "" && t.Name == "error"
+}
+
+// 可变参数在结构体中使用切片 e.g. ...*Foo => []*Foo
+func variadicToSlice(t cst.Type) cst.Type {
+	elem := t
+	switch {
+	case t.ElementType != nil:
+		elem.GoType = cst.ArrayType
+	case t.KeyType != nil:
+		elem.GoType = cst.MapType
+	case cst.IsBasicType(t.Name):
+		elem.GoType = cst.BasicType
+	default:
+		elem.GoType = cst.StructType
+	}
+
+	base := elem.BaseType
+	if elem.GoType == cst.ArrayType || elem.GoType == cst.MapType {
+		base.Nested = &elem
+	}
+	return cst.Type{
+		BaseType: cst.BaseType{
+			X:        elem.X,
+			Name:     "[]" + TypeString(elem, ""),
+			GoType:   cst.ArrayType,
+			Position: t.Position,
+		},
+		ElementType: &base,
+	}
+}
+
+// 类型的完整定义，packageName不为空时当前包中的类型带上包名
+// e.g. map[string][]*User => map[string][]*addservice.User
+func TypeString(t cst.Type, packageName string) string {
+	var star string
+	if t.Star {
+		star = "*"
+	}
+
+	switch t.GoType {
+	case cst.ArrayType:
+		return star + "[]" + TypeString(t.ElementType.FullType(), packageName)
+	case cst.MapType:
+		return star + "map[" + TypeString(t.KeyType.FullType(), packageName) + "]" +
+			TypeString(t.ValueType.FullType(), packageName)
+	case cst.EllipsisType:
+		return "..." + strings.TrimPrefix(TypeString(variadicToSlice(t), packageName), "[]")
+	case cst.StructType:
+		switch {
+		case t.X != "":
+			return star + t.X + "." + t.Name
+		case packageName != "":
+			return star + packageName + "." + t.Name
+		}
+		return star + t.Name
+	case cst.BasicType:
+		if t.X != "" {
+			return star + t.X + "." + t.Name
+		}
+		return star + t.Name
+	}
+	return star + t.Name
+}
+
+// 带有合成结构体的语法树，非标准签名的方法替换为标准签名
+// protobuf、thrift及transport只需要处理标准签名的方法
+type envelopeTree struct {
+	cst.ConcreteSyntaxTree
+	interfaces []cst.Interface
+	structs    []*cst.Struct
+	structMap  map[string]map[string]*cst.Struct
+}
+
+// serviceSuffix: 需要处理的接口后缀 e.g. Service
+func NewEnvelopeTree(tree cst.ConcreteSyntaxTree, serviceSuffix string) (cst.ConcreteSyntaxTree, error) {
+	t := &envelopeTree{
+		ConcreteSyntaxTree: tree,
+		structs:            append([]*cst.Struct{}, tree.Structs()...),
+		structMap:          map[string]map[string]*cst.Struct{},
+	}
+	for pkg, structs := range tree.StructMap() {
+		t.structMap[pkg] = map[string]*cst.Struct{}
+		for name, strc := range structs {
+			t.structMap[pkg][name] = strc
+		}
+	}
+	if t.structMap[tree.PackageName()] == nil {
+		t.structMap[tree.PackageName()] = map[string]*cst.Struct{}
+	}
+
+	var synthesized bool
+	for _, iface := range tree.Interfaces() {
+		if !strings.HasSuffix(iface.Name, serviceSuffix) {
+			t.interfaces = append(t.interfaces, iface)
+			continue
+		}
+
+		envelopes, err := NewEnvelopes(tree.PackageName(), iface)
+		if err != nil {
+			return nil, err
+		}
+
+		normalized := cst.Interface{Name: iface.Name}
+		for _, e := range envelopes {
+			if e.Standard {
+				normalized.Methods = append(normalized.Methods, e.Method)
+				continue
+			}
+			synthesized = true
+			normalized.Methods = append(normalized.Methods, e.StandardMethod())
+			for _, strc := range []*cst.Struct{e.Request, e.Response} {
+				if _, found := t.structMap[tree.PackageName()][strc.Name]; found {
+					return nil, fmt.Errorf("Struct(%s) synthesized for method(%s) conflicts with an existing type", strc.Name, e.Method.Name)
+				}
+				t.structs = append(t.structs, strc)
+				t.structMap[tree.PackageName()][strc.Name] = strc
+			}
+		}
+		t.interfaces = append(t.interfaces, normalized)
+	}
+
+	if !synthesized {
+		return tree, nil
+	}
+	return t, nil
+}
+
+func (t *envelopeTree) Interfaces() []cst.Interface {
+	return t.interfaces
+}
+
+func (t *envelopeTree) Structs() []*cst.Struct {
+	return t.structs
+}
+
+func (t *envelopeTree) StructMap() map[string]map[string]*cst.Struct {
+	return t.structMap
+}
+
+// 使用合成结构体的标准签名
+func (e Envelope) StandardMethod() cst.Method {
+	structType := func(name string) cst.Type {
+		return cst.Type{BaseType: cst.BaseType{Star: true, Name: name, GoType: cst.StructType}}
+	}
+	return cst.Method{
+		Name:       e.Method.Name,
+		Directives: e.Method.Directives,
+		Params: []cst.Field{
+			{Name: "ctx", Type: cst.Type{BaseType: cst.BaseType{X: "context", Name: "Context", GoType: cst.StructType}}},
+			{Name: "req", Type: structType(e.Request.Name)},
+		},
+		Results: []cst.Field{
+			{Name: "resp", Type: structType(e.Response.Name)},
+			{Name: "err", Type: cst.Type{BaseType: cst.BaseType{Name: "error", GoType: cst.BasicType}}},
+		},
+	}
+}
+
+// 方法的参数列表，packageName为service所在的包名
+// e.g. ctx context.Context, id int64, names ...string
+func (e Envelope) ParamList(packageName string) string {
+	var params []string
+	if e.HasContext {
+		params = append(params, "ctx context.Context")
+	}
+	for _, p := range e.Params {
+		typ := TypeString(p.Type, packageName)
+		if p.Variadic {
+			typ = "..." + strings.TrimPrefix(typ, "[]")
+		}
+		params = append(params, p.Name+" "+typ)
+	}
+	return strings.Join(params, ", ")
+}
+
+// 方法的命名返回值列表 e.g. r0 addservice.User, err error
+func (e Envelope) ResultList(packageName string) string {
+	var results []string
+	for _, r := range e.Results {
+		results = append(results, r.Name+" "+TypeString(r.Type, packageName))
+	}
+	results = append(results, "err error")
+	return strings.Join(results, ", ")
+}
+
+// 使用请求结构体调用方法的参数 e.g. ctx, req.Id, req.Names...
+func (e Envelope) Args(alias string) string {
+	var args []string
+	if e.HasContext {
+		args = append(args, "ctx")
+	}
+	for _, p := range e.Params {
+		arg := alias + "." + p.Field
+		if p.Variadic {
+			arg += "..."
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, ", ")
+}
+
+// 方法返回值赋值到响应结构体 e.g. resp.Result, err =
+func (e Envelope) Assign(alias string) string {
+	var lhs []string
+	for _, r := range e.Results {
+		lhs = append(lhs, alias+"."+r.Field)
+	}
+	lhs = append(lhs, "err")
+	return strings.Join(lhs, ", ") + " = "
+}
+
+// 从响应结构体中取出返回值 e.g. resp.Result, nil
+func (e Envelope) Returns(alias string) string {
+	var values []string
+	for _, r := range e.Results {
+		values = append(values, alias+"."+r.Field)
+	}
+	values = append(values, "nil")
+	return strings.Join(values, ", ")
+}
+
+// 转发调用时的参数 e.g. ctx, id, names...
+func (e Envelope) ParamNames() string {
+	var names []string
+	if e.HasContext {
+		names = append(names, "ctx")
+	}
+	for _, p := range e.Params {
+		name := p.Name
+		if p.Variadic {
+			name += "..."
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
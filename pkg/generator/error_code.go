@@ -0,0 +1,29 @@
+package generator
+
+// 生成的服务错误码，取值与gRPC的codes.Code一致，transport直接转换为gRPC status
+// HTTPStatus为HTTP transport返回的状态码，对应关系参考grpc-gateway
+type ErrorCode struct {
+	Name       string // 错误码名称 e.g. InvalidArgument，生成Code<Name>常量及Err<Name>哨兵错误
+	Value      int
+	HTTPStatus string // 生成代码中的状态码表达式 e.g. http.StatusBadRequest
+	Comment    string
+}
+
+var ErrorCodes = []ErrorCode{
+	{"Canceled", 1, "499", "请求被调用方取消"},
+	{"Unknown", 2, "http.StatusInternalServerError", "未知错误，没有指定错误码的error均视为该错误"},
+	{"InvalidArgument", 3, "http.StatusBadRequest", "请求参数不合法"},
+	{"DeadlineExceeded", 4, "http.StatusGatewayTimeout", "请求超时"},
+	{"NotFound", 5, "http.StatusNotFound", "请求的资源不存在"},
+	{"AlreadyExists", 6, "http.StatusConflict", "创建的资源已经存在"},
+	{"PermissionDenied", 7, "http.StatusForbidden", "没有权限"},
+	{"ResourceExhausted", 8, "http.StatusTooManyRequests", "资源耗尽 e.g. 超出限流"},
+	{"FailedPrecondition", 9, "http.StatusBadRequest", "系统状态不满足执行条件"},
+	{"Aborted", 10, "http.StatusConflict", "操作被中止 e.g. 并发冲突"},
+	{"OutOfRange", 11, "http.StatusBadRequest", "超出有效范围"},
+	{"Unimplemented", 12, "http.StatusNotImplemented", "方法未实现"},
+	{"Internal", 13, "http.StatusInternalServerError", "内部错误"},
+	{"Unavailable", 14, "http.StatusServiceUnavailable", "服务不可用，可以重试"},
+	{"DataLoss", 15, "http.StatusInternalServerError", "数据丢失或损坏"},
+	{"Unauthenticated", 16, "http.StatusUnauthorized", "未认证"},
+}
@@ -302,7 +302,7 @@ func genStubs(opts Options, recv string, fns []Func) error {
 		recvName = strings.TrimPrefix(ss[1], "*")
 	}
 
-	err := tmpl.Execute(opts.writer, map[string]interface{}{
+	err := gen.ExecuteTemplate(tmpl, opts.writer, map[string]interface{}{
 		"PackageName": opts.packageName,
 		"Recv":        recv,
 		"Funcs":       fns,
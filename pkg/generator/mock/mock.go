@@ -0,0 +1,70 @@
+package mock
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	gen "ezrpro.com/micro/kit/pkg/generator"
+	"ezrpro.com/micro/kit/pkg/utils"
+)
+
+// 生成可编程的service mock，每个方法对应一个XFunc字段，并记录调用参数
+type MockGenerator struct {
+	cst  cst.ConcreteSyntaxTree
+	opts Options
+}
+
+func NewMockGenerator(t cst.ConcreteSyntaxTree, opts ...Option) gen.Generator {
+	options := newOptions(opts...)
+
+	return &MockGenerator{
+		cst:  t,
+		opts: options,
+	}
+}
+
+func (g *MockGenerator) Generate() error {
+	for tplName, readWriter := range g.opts.readWriterMap {
+		tplBody, err := ioutil.ReadAll(readWriter.template)
+		if err != nil {
+			return err
+		}
+
+		t := template.New(string(tplName)).Funcs(map[string]interface{}{
+			"BasePath":              filepath.Base,
+			"ToLowerFirstCamelCase": utils.ToLowerFirstCamelCase,
+			"TypeString":            gen.TypeString,
+		})
+		t, err = t.Parse(string(tplBody))
+		if err != nil {
+			return err
+		}
+
+		serviceIface, err := gen.FilterInterface(g.cst.Interfaces(), g.opts.serviceSuffix)
+		if err != nil {
+			return err
+		}
+
+		envelopes, err := gen.NewEnvelopes(g.cst.PackageName(), serviceIface)
+		if err != nil {
+			return err
+		}
+
+		err = gen.ExecuteTemplate(t, readWriter.writer, map[string]interface{}{
+			"BaseServiceName":   g.opts.baseServiceName,
+			"PackageName":       g.opts.mockPackageName,
+			"ServiceName":       serviceIface.Name,
+			"ServiceImportPath": utils.GetServiceImportPath(g.opts.baseServiceName),
+			// mock的方法保持service中原有的签名
+			"Envelopes": envelopes,
+			// 方法签名中引用的其他包，未使用的import由goimports移除
+			"ServiceImports": g.cst.Imports(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
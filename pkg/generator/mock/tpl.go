@@ -0,0 +1,112 @@
+package mock
+
+var DefaultMockTemplate = `
+{{$servicePackageName := BasePath .ServiceImportPath}}
+package {{.PackageName}}
+
+import (
+	"context"
+	"sync"
+	{{- range .ServiceImports}}
+	{{.Alias}} {{.Path}}
+	{{- end}}
+
+	{{$servicePackageName}} "{{.ServiceImportPath}}"
+)
+
+var _ {{$servicePackageName}}.{{.ServiceName}} = (*Service)(nil)
+
+// Service is a programmable {{$servicePackageName}}.{{.ServiceName}} for tests.
+// Each method calls the corresponding func field and records the arguments,
+// methods without a func return Unimplemented error.
+type Service struct {
+{{- range .Envelopes}}
+{{- $method := .Method}}
+{{- if .Standard}}
+	{{$method.Name}}Func func(ctx context.Context, req *{{$servicePackageName}}.{{$method.Name}}Request) (*{{$servicePackageName}}.{{$method.Name}}Response, error)
+{{- else}}
+	{{$method.Name}}Func func({{.ParamList $servicePackageName}}) ({{.ResultList $servicePackageName}})
+{{- end}}
+{{- end}}
+
+	mu    sync.Mutex
+	calls struct {
+	{{- range .Envelopes}}
+		{{.Method.Name}} []{{.Method.Name}}Call
+	{{- end}}
+	}
+}
+
+{{range $index, $envelope := .Envelopes}}
+{{$method := $envelope.Method}}
+// {{$method.Name}}Call records the arguments of a call to {{$method.Name}}.
+type {{$method.Name}}Call struct {
+{{- if $envelope.Standard}}
+	Ctx context.Context
+	Req *{{$servicePackageName}}.{{$method.Name}}Request
+{{- else}}
+	{{- if $envelope.HasContext}}
+	Ctx context.Context
+	{{- end}}
+	{{- range $envelope.Params}}
+	{{.Field}} {{TypeString .Type $servicePackageName}}
+	{{- end}}
+{{- end}}
+}
+
+{{if $envelope.Standard}}
+func (s *Service) {{$method.Name}}(ctx context.Context, req *{{$servicePackageName}}.{{$method.Name}}Request) (resp *{{$servicePackageName}}.{{$method.Name}}Response, err error) {
+	s.mu.Lock()
+	s.calls.{{$method.Name}} = append(s.calls.{{$method.Name}}, {{$method.Name}}Call{Ctx: ctx, Req: req})
+	s.mu.Unlock()
+
+	if s.{{$method.Name}}Func == nil {
+		return nil, {{$servicePackageName}}.NewUnimplemented("{{$method.Name}}Func is not set")
+	}
+	return s.{{$method.Name}}Func(ctx, req)
+}
+{{else}}
+func (s *Service) {{$method.Name}}({{$envelope.ParamList $servicePackageName}}) ({{$envelope.ResultList $servicePackageName}}) {
+	s.mu.Lock()
+	s.calls.{{$method.Name}} = append(s.calls.{{$method.Name}}, {{$method.Name}}Call{
+	{{- if $envelope.HasContext}}
+		Ctx: ctx,
+	{{- end}}
+	{{- range $envelope.Params}}
+		{{.Field}}: {{.Name}},
+	{{- end}}
+	})
+	s.mu.Unlock()
+
+	if s.{{$method.Name}}Func == nil {
+		err = {{$servicePackageName}}.NewUnimplemented("{{$method.Name}}Func is not set")
+		return
+	}
+	return s.{{$method.Name}}Func({{$envelope.ParamNames}})
+}
+{{end}}
+
+// {{$method.Name}}Calls returns the recorded calls to {{$method.Name}} in order.
+func (s *Service) {{$method.Name}}Calls() []{{$method.Name}}Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]{{$method.Name}}Call(nil), s.calls.{{$method.Name}}...)
+}
+
+// {{$method.Name}}CallCount returns how many times {{$method.Name}} has been called.
+func (s *Service) {{$method.Name}}CallCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.calls.{{$method.Name}})
+}
+{{end}}
+
+// Reset clears the recorded calls, the func fields are kept.
+func (s *Service) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+{{- range .Envelopes}}
+	s.calls.{{.Method.Name}} = nil
+{{- end}}
+}
+`
@@ -0,0 +1,319 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+)
+
+const (
+	// 自定义的tag名称
+	PBTagName = "pb"
+	// 结构体级别的声明使用空白字段承载
+	// e.g. _ struct{} `pb:"reserved=2 15 9-11,reserved_name=foo bar"`
+	BlankFieldName = "_"
+
+	MinFieldNumber         = 1
+	MaxFieldNumber         = 1<<29 - 1
+	FirstReservedNumber    = 19000 // protobuf实现自身保留的序列号区间
+	LastReservedNumber     = 19999
+	reservedRangeSeparator = "-"
+)
+
+// pb tag的解析结果
+// protobuf本身的tag(protobuf)中的数据类型不能直接作为proto中的数据类型使用
+// 能使用的仅序列号和字段名
+// 在这里自定义新增了一个tag(pb)用作name,seq,type的重定义以及字段选项的声明
+// e.g. `pb:"name=user_id,seq=2,json_name=userId,deprecated,optional"`
+// nested 表示字段的类型定义在当前message中 e.g. `pb:"nested"`
+// protobuf和thrift等IDL生成器共用这套规则，保证生成的字段序列号一致
+type PBTag struct {
+	Name       string
+	Seq        int
+	GrpcType   string
+	JSONName   string
+	Deprecated bool
+	Optional   bool
+	Nested     bool
+}
+
+// 结构体级别声明的保留序列号及字段名
+// e.g. _ struct{} `pb:"reserved=2 15 9-11,reserved_name=foo bar"`
+type PBReserved struct {
+	Ranges [][2]int // 闭区间 单个序列号时两端相同
+	Names  []string
+}
+
+func (r PBReserved) HasNumber(seq int) bool {
+	for _, rg := range r.Ranges {
+		if seq >= rg[0] && seq <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r PBReserved) HasName(name string) bool {
+	for _, n := range r.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func IsBlankField(field cst.Field) bool {
+	return field.Name == BlankFieldName
+}
+
+func GetPBTagStr(field cst.Field) string {
+	if field.Tag == "" {
+		return ""
+	}
+	return reflect.StructTag(field.Tag).Get(PBTagName)
+}
+
+func splitPBTag(pbTag string) (key, value string) {
+	if i := strings.Index(pbTag, "="); i >= 0 {
+		return strings.TrimSpace(pbTag[:i]), strings.TrimSpace(pbTag[i+1:])
+	}
+	return strings.TrimSpace(pbTag), ""
+}
+
+// 解析字段上的pb tag
+func ParsePBTag(field cst.Field) (PBTag, error) {
+	var tag PBTag
+	pbTagStr := GetPBTagStr(field)
+	if pbTagStr == "" {
+		return tag, nil
+	}
+
+	for _, s := range strings.Split(pbTagStr, ",") {
+		key, value := splitPBTag(s)
+		switch key {
+		case "":
+		case "name":
+			if value == "" {
+				return tag, fmt.Errorf("tag(%s) name can not be empty", s)
+			}
+			tag.Name = value
+		case "seq":
+			seq, err := strconv.Atoi(value)
+			if err != nil {
+				return tag, err
+			}
+			if err := checkFieldNumber(seq); err != nil {
+				return tag, err
+			}
+			tag.Seq = seq
+		case "type":
+			if value == "" {
+				return tag, fmt.Errorf("tag(%s) type can not be empty", s)
+			}
+			tag.GrpcType = value
+		case "json_name":
+			if value == "" {
+				return tag, fmt.Errorf("tag(%s) json_name can not be empty", s)
+			}
+			tag.JSONName = value
+		case "deprecated":
+			tag.Deprecated = true
+		case "optional":
+			tag.Optional = true
+		case "nested":
+			tag.Nested = true
+		default:
+			return tag, fmt.Errorf("unknown tag(%s)", s)
+		}
+	}
+	return tag, nil
+}
+
+// 解析空白字段上声明的保留序列号及字段名
+func ParsePBReserved(strc *cst.Struct) (PBReserved, error) {
+	var reserved PBReserved
+	for _, field := range strc.Fields {
+		if !IsBlankField(field) {
+			continue
+		}
+
+		pbTagStr := GetPBTagStr(field)
+		if pbTagStr == "" {
+			continue
+		}
+
+		for _, s := range strings.Split(pbTagStr, ",") {
+			key, value := splitPBTag(s)
+			switch key {
+			case "":
+			case "reserved":
+				for _, r := range strings.Fields(value) {
+					rg, err := parseReservedRange(r)
+					if err != nil {
+						return reserved, fmt.Errorf("tag(%s) %v", s, err)
+					}
+					reserved.Ranges = append(reserved.Ranges, rg)
+				}
+			case "reserved_name":
+				reserved.Names = append(reserved.Names, strings.Fields(value)...)
+			default:
+				return reserved, fmt.Errorf("unknown tag(%s) of blank field", s)
+			}
+		}
+	}
+	return reserved, nil
+}
+
+// 2 => [2, 2]   9-11 => [9, 11]
+func parseReservedRange(s string) ([2]int, error) {
+	var (
+		rg    [2]int
+		parts = strings.SplitN(s, reservedRangeSeparator, 2)
+	)
+	for i, part := range parts {
+		seq, err := strconv.Atoi(part)
+		if err != nil {
+			return rg, err
+		}
+		if err := checkFieldNumber(seq); err != nil {
+			return rg, err
+		}
+		rg[i] = seq
+	}
+	if len(parts) == 1 {
+		rg[1] = rg[0]
+	}
+	if rg[0] > rg[1] {
+		return rg, fmt.Errorf("invalid reserved range(%s)", s)
+	}
+	return rg, nil
+}
+
+func checkFieldNumber(seq int) error {
+	if seq < MinFieldNumber || seq > MaxFieldNumber {
+		return fmt.Errorf("field number(%d) must be in [%d, %d]", seq, MinFieldNumber, MaxFieldNumber)
+	}
+	if seq >= FirstReservedNumber && seq <= LastReservedNumber {
+		return fmt.Errorf("field number(%d) is reserved for the protobuf implementation [%d, %d]",
+			seq, FirstReservedNumber, LastReservedNumber)
+	}
+	return nil
+}
+
+// 未设置seq时顺延生成序列号，并跳过保留的序列号
+func NextFieldNumber(seq int, reserved PBReserved) int {
+	seq++
+	for reserved.HasNumber(seq) ||
+		(seq >= FirstReservedNumber && seq <= LastReservedNumber) {
+		seq++
+	}
+	return seq
+}
+
+// 检查结构体中pb tag的合法性，并返回结构体声明的保留序列号及字段名
+func CheckPBTag(strc *cst.Struct) (PBReserved, error) {
+	reserved, err := ParsePBReserved(strc)
+	if err != nil {
+		return reserved, fmt.Errorf("Unsupport grpc pb StructName:%s reserved error(%v)\n %s",
+			strc.Name, err, strc.Position)
+	}
+
+	var (
+		fieldCount    int
+		useSeqCount   int
+		seqMap        = map[int]cst.Field{}    // key: seq value: field
+		nameMap       = map[string]cst.Field{} // key: name value: field
+		jsonNameMap   = map[string]cst.Field{} // key: json_name value: field
+		checkConflict = func(tagName, value string, m map[string]cst.Field, field cst.Field) error {
+			field2, found := m[value]
+			if !found {
+				m[value] = field
+				return nil
+			}
+			return fmt.Errorf("StructName:%s Field:%s and Field:%s have the same tag:%s(%s)\n  Field:%s %s %s\n  Field:%s %s %s",
+				strc.Name, field.Name, field2.Name, tagName, value,
+				field.Name, field.Pos, field.Tag,
+				field2.Name, field2.Pos, field2.Tag,
+			)
+		}
+	)
+	for _, field := range strc.Fields {
+		if IsBlankField(field) {
+			continue
+		}
+		fieldCount++
+
+		// 设置seq必须所有字段设置，否则会出现seq不唯一的情况
+		tag, err := ParsePBTag(field)
+		if err != nil {
+			return reserved, fmt.Errorf("Unsupport grpc pb StructName:%s Field:%s tag(%s) error(%v)\n %s",
+				strc.Name, field.Name, GetPBTagStr(field), err, field.Pos)
+		}
+
+		if tag.Seq != 0 {
+			useSeqCount++
+
+			if reserved.HasNumber(tag.Seq) {
+				return reserved, fmt.Errorf("StructName:%s Field:%s use the reserved tag:seq(%d)\n %s",
+					strc.Name, field.Name, tag.Seq, field.Pos)
+			}
+
+			field2, found := seqMap[tag.Seq]
+			if !found {
+				seqMap[tag.Seq] = field
+			} else {
+				return reserved, fmt.Errorf("StructName:%s Field:%s and Field:%s have the same tag:seq(%d)\n  Field:%s %s %s\n  Field:%s %s %s",
+					strc.Name, field.Name, field2.Name, tag.Seq,
+					field.Name, field.Pos, field.Tag,
+					field2.Name, field2.Pos, field2.Tag,
+				)
+			}
+		}
+
+		name := field.Name
+		if tag.Name != "" {
+			name = tag.Name
+		}
+		if reserved.HasName(name) {
+			return reserved, fmt.Errorf("StructName:%s Field:%s use the reserved name(%s)\n %s",
+				strc.Name, field.Name, name, field.Pos)
+		}
+		if err := checkConflict("name", name, nameMap, field); err != nil {
+			return reserved, err
+		}
+
+		if tag.JSONName != "" {
+			if err := checkConflict("json_name", tag.JSONName, jsonNameMap, field); err != nil {
+				return reserved, err
+			}
+		}
+
+		// proto3 optional 仅支持单值字段
+		if tag.Optional && isRepeatedField(field.Type, tag.GrpcType) {
+			return reserved, fmt.Errorf("StructName:%s Field:%s repeated or map field can not be optional\n %s",
+				strc.Name, field.Name, field.Pos)
+		}
+	}
+
+	// 使用了seq但是并没有给所有的字段加上，这种情况没办法增加序列号
+	if useSeqCount > 0 && useSeqCount != fieldCount {
+		return reserved, fmt.Errorf("If you use the \"pb\" tag seq you must set for(StructName:%s) all fields\n %s", strc.Name, strc.Position)
+	}
+	return reserved, nil
+}
+
+func isRepeatedField(t cst.Type, grpcType string) bool {
+	if grpcType != "" {
+		return strings.HasPrefix(grpcType, "repeated ") || strings.HasPrefix(grpcType, "map<")
+	}
+	switch t.GoType {
+	case cst.ArrayType:
+		return strings.TrimSpace(t.Name) != "[]byte"
+	case cst.MapType:
+		return true
+	}
+	return false
+}
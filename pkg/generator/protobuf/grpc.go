@@ -3,7 +3,7 @@ package protobuf
 import (
 	"fmt"
 	"path/filepath"
-	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -13,10 +13,13 @@ import (
 	"ezrpro.com/micro/kit/pkg/utils"
 )
 
+const googleAPIAnnotations = "google/api/annotations.proto"
+
 type ProtobufGenerator struct {
 	cst           cst.ConcreteSyntaxTree
 	opts          Options
 	referenceType map[string]struct{} // key: [struct.Name or type.Name] val: struct{}{}
+	nested        nestedTypes
 }
 
 func NewProtobufGenerator(t cst.ConcreteSyntaxTree, opts ...Option) gen.Generator {
@@ -30,6 +33,22 @@ func NewProtobufGenerator(t cst.ConcreteSyntaxTree, opts ...Option) gen.Generato
 }
 
 func (g *ProtobufGenerator) Generate() error {
+	// 非标准签名的方法使用合成的请求及响应结构体
+	tree, err := gen.NewEnvelopeTree(g.cst, g.opts.serviceSuffix)
+	if err != nil {
+		return err
+	}
+	g.cst = tree
+
+	// kit:http声明的路径参数必须对应请求结构体中的字段
+	serviceIface, err := gen.FilterInterface(g.cst.Interfaces(), g.opts.serviceSuffix)
+	if err != nil {
+		return err
+	}
+	if err := gen.CheckHTTPRoutes(g.cst, serviceIface); err != nil {
+		return err
+	}
+
 	baseServiceName := service.GetBaseServiceName(g.cst.PackageName(), g.opts.serviceSuffix)
 	protobufPath := utils.GetProtobufFilePath(baseServiceName)
 	protobufPackageName := filepath.Base(protobufPath)
@@ -39,9 +58,22 @@ func (g *ProtobufGenerator) Generate() error {
 	w.P("package %s;", protobufPackageName)
 	w.P(``)
 
+	if imports := g.protoImports(); len(imports) > 0 {
+		for _, imp := range imports {
+			w.P(`import "%s";`, imp)
+			w.P(``)
+		}
+		w.P(``)
+	}
+
+	g.nested = parseNestedTypes(g.cst)
+
 	for _, i := range g.cst.Interfaces() {
-		g.generateInterface(i)
+		if err := g.generateInterface(i); err != nil {
+			return err
+		}
 	}
+	g.referenceNestedTypes()
 
 	for i, strc := range g.cst.Structs() {
 		// 跳过制定过滤的struct 和 未使用的struct
@@ -53,45 +85,133 @@ func (g *ProtobufGenerator) Generate() error {
 			continue
 		}
 
-		if strc.Type == nil {
-			g.generateMessage(strc)
-		} else {
-			g.generateEnum(strc)
+		// 嵌套类型在父message中生成
+		if g.nested.isNested(strc.Name) {
+			continue
+		}
+
+		if err := g.generateType(strc); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+func (g *ProtobufGenerator) generateType(strc *cst.Struct) error {
+	if strc.Type == nil {
+		return g.generateMessage(strc)
+	}
+	g.generateEnum(strc)
+	return nil
+}
+
 func (g *ProtobufGenerator) isUseStruct(structName string) bool {
 	_, found := g.referenceType[structName]
 	return found
 }
 
-func (g *ProtobufGenerator) generateInterface(i cst.Interface) {
+// 嵌套类型只在父message中生成，父message也会生成其中所有的嵌套类型
+// 被引用的嵌套类型的外层message，以及被引用的message中的嵌套类型都需要生成
+// e.g. 只引用了Outer_Inner时，Outer以及Outer中的其他嵌套类型同样需要生成
+func (g *ProtobufGenerator) referenceNestedTypes() {
+	for changed := true; changed; {
+		changed = false
+
+		var names []string
+		for name := range g.referenceType {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			related := append([]string{}, g.nested.children(name)...)
+			if parent, found := g.nested.parentMap[name]; found {
+				related = append(related, parent)
+			}
+			for _, r := range related {
+				if g.isUseStruct(r) {
+					continue
+				}
+				g.recursiveFieldType(cst.Type{BaseType: cst.BaseType{GoType: cst.StructType, Name: r}})
+				changed = changed || g.isUseStruct(r)
+			}
+		}
+	}
+}
+
+func (g *ProtobufGenerator) generateInterface(i cst.Interface) error {
 	w := NewSugerWriter(g.opts.writer)
 	serviceName := g.opts.serviceNameNormalizer.Normalize(i.Name)
 	w.P(`service %s {`, serviceName)
 	w.P(``)
 	for _, method := range i.Methods {
-		g.generateServiceMethod(method)
+		if err := g.generateServiceMethod(method); err != nil {
+			return err
+		}
 	}
 	w.P(`}`)
 	w.P(``)
+	return nil
 }
 
-func (g *ProtobufGenerator) generateServiceMethod(method cst.Method) {
+func (g *ProtobufGenerator) generateServiceMethod(method cst.Method) error {
 	w := NewSugerWriter(g.opts.writer)
 	w.P(`rpc %s (`, method.Name)
-	g.generateServiceMethodFields(method.Params)
+	if err := g.generateServiceMethodFields(method.Params); err != nil {
+		return err
+	}
 	w.P(`)`)
 	w.P(` returns (`)
-	g.generateServiceMethodFields(method.Results)
-	w.P(`) {}`)
+	if err := g.generateServiceMethodFields(method.Results); err != nil {
+		return err
+	}
+	options := g.serviceMethodOptions(method)
+	if len(options) == 0 {
+		w.P(`) {}`)
+		w.P(``)
+		return nil
+	}
+	w.P(`) {`)
+	w.P(``)
+	for _, option := range options {
+		w.P("%s", option)
+		w.P(``)
+	}
+	w.P(`}`)
 	w.P(``)
+	return nil
+}
+
+// 方法注释中的指令对应的rpc选项
+// kit:http => google.api.http  kit:idempotent => idempotency_level
+func (g *ProtobufGenerator) serviceMethodOptions(method cst.Method) []string {
+	var options []string
+	if method.Directives.HasHTTP() {
+		// 路径参数使用proto中的字段名
+		path := method.Directives.HTTPPath
+		params, _ := gen.NewPathParams(g.cst, method)
+		for _, param := range params {
+			fieldName := param.Field.Name
+			if tag, _ := gen.ParsePBTag(param.Field); tag.Name != "" {
+				fieldName = tag.Name
+			}
+			path = strings.Replace(path, "{"+param.Name+"}", "{"+fieldName+"}", 1)
+		}
+
+		option := fmt.Sprintf(`option (google.api.http) = { %s: "%s"`, strings.ToLower(method.Directives.HTTPMethod), path)
+		if method.Directives.HTTPBody() {
+			option += ` body: "*"`
+		}
+		options = append(options, option+` };`)
+	}
+	if method.Directives.Idempotent {
+		options = append(options, `option idempotency_level = IDEMPOTENT;`)
+	}
+	return options
 }
 
-func (g *ProtobufGenerator) generateServiceMethodFields(fields []cst.Field) {
+func (g *ProtobufGenerator) generateServiceMethodFields(fields []cst.Field) error {
 	w := NewSugerWriter(g.opts.writer)
 	for _, field := range fields {
 		if g.opts.typeFilter(field.Type) {
@@ -99,12 +219,12 @@ func (g *ProtobufGenerator) generateServiceMethodFields(fields []cst.Field) {
 		}
 
 		if field.Type.GoType == cst.BasicType {
-			panic(fmt.Sprintf("gRPC Request parameters unsupprt %s type(go type name:%s)", field.Type.GoType, field.Type.Name))
+			return fmt.Errorf("gRPC Request parameters unsupprt %s type(go type name:%s)", field.Type.GoType, field.Type.Name)
 		}
 
-		grpcType, ignore := g.getGrpcType(field.Type)
-		if ignore {
-			continue
+		grpcType, err := g.getGrpcType(field.Type)
+		if err != nil {
+			return err
 		}
 
 		g.recursiveFieldType(field.Type)
@@ -113,24 +233,41 @@ func (g *ProtobufGenerator) generateServiceMethodFields(fields []cst.Field) {
 		// TODO 提示gRPC参数不能超过1位
 		break
 	}
+	return nil
 }
 
-func (g *ProtobufGenerator) getGrpcType(t cst.Type) (grpcType string, ignore bool) {
+func (g *ProtobufGenerator) getGrpcType(t cst.Type) (string, error) {
 	grpcType, found := g.GoType2GrpcType(t)
-	if !found {
-		pkg := g.cst.PackageName()
-		// 尝试从type所在的包查找
-		if t.X != "" {
-			pkg = t.X
-		}
+	if found {
+		return grpcType, nil
+	}
 
-		grpcType, found := g.findStructInASTStructMap(pkg, t.Name)
-		if !found {
-			panic(fmt.Sprintf("Not found (%+v) in grpc type mapping(pkg:%s) and ast StructMap", t, pkg))
+	switch t.GoType {
+	case cst.ArrayType, cst.MapType:
+		// proto3的repeated及map的元素不能再是repeated或者map
+		if isCollectionType(t.ElementType) || isCollectionType(t.ValueType) {
+			return "", fmt.Errorf("Unsupport grpc type(%s), protobuf has no nested repeated or map field, wrap the inner collection in a struct instead", t.String())
 		}
-		return grpcType, false
+		return "", fmt.Errorf("Unsupport grpc type(%s)", t.String())
+	case cst.CrossProtocolUnsupportType:
+		return "", fmt.Errorf("This type(%s %s) is unsupport cross protocol", t.Name, t.GoType)
 	}
-	return grpcType, false
+
+	pkg := g.cst.PackageName()
+	// 尝试从type所在的包查找
+	if t.X != "" {
+		pkg = t.X
+	}
+
+	grpcType, found = g.findStructInASTStructMap(pkg, t.Name)
+	if !found {
+		return "", fmt.Errorf("Not found (%+v) in grpc type mapping(pkg:%s) and ast StructMap", t, pkg)
+	}
+	return grpcType, nil
+}
+
+func isCollectionType(t *cst.BaseType) bool {
+	return t != nil && (t.GoType == cst.ArrayType || t.GoType == cst.MapType)
 }
 
 func (g *ProtobufGenerator) recursiveFieldType(t cst.Type) {
@@ -144,6 +281,10 @@ func (g *ProtobufGenerator) recursiveFieldType(t cst.Type) {
 	if typ.GoType != cst.StructType {
 		return
 	}
+	// 自定义类型转换为proto已有的类型，不需要生成message
+	if _, found := g.opts.typeConverters.Find(typ); found {
+		return
+	}
 	for _, structMap := range g.cst.StructMap() {
 		strc, found := structMap[typ.Name]
 		if found {
@@ -162,64 +303,92 @@ func (g *ProtobufGenerator) recursiveFieldType(t cst.Type) {
 	}
 }
 
-func (g *ProtobufGenerator) generateMessage(strc *cst.Struct) {
+func (g *ProtobufGenerator) generateMessage(strc *cst.Struct) error {
 	w := NewSugerWriter(g.opts.writer)
-	w.P(`message %s {`, strc.Name)
+	w.P(`message %s {`, g.nested.localName(strc.Name))
 	w.P(``)
 
-	g.checkPBTag(strc)
+	reserved, err := gen.CheckPBTag(strc)
+	if err != nil {
+		return err
+	}
+	for _, statement := range reservedStatements(reserved) {
+		w.P("%s", statement)
+		w.P(``)
+	}
 
-	for i, field := range strc.Fields {
-		var (
-			fieldName = field.Name
-			seq       = i + 1
-			grpcType  string
-			ignore    bool
-			tag       = reflect.StructTag(field.Tag)
-			pbTagStr  = tag.Get("pb")
-		)
+	for _, child := range g.nested.children(strc.Name) {
+		if childStrc, found := g.cst.StructMap()[strc.PackageName][child]; found {
+			if err := g.generateType(childStrc); err != nil {
+				return err
+			}
+		}
+	}
 
-		grpcType, ignore = g.getGrpcType(field.Type)
-		if ignore {
+	var seq int
+	for _, field := range strc.Fields {
+		// 空白字段仅用于声明结构体级别的pb tag
+		if gen.IsBlankField(field) {
 			continue
 		}
 
-		// protobuf本身的tag(protobuf)中的数据类型不能直接作为proto中的数据类型使用
-		// 能使用的仅序列号和字段名
-		// 在这里自定义新增了一个tag(pb)用作name,seq,type的重定义
-		// TODO 设置seq必须所有字段设置，否则会出现seq不唯一的情况
-		if field.Tag != "" && pbTagStr != "" {
-			pbTags := strings.Split(pbTagStr, ",")
-			for _, pbTag := range pbTags {
-				switch {
-				case strings.HasPrefix(pbTag, "name="):
-					fieldName = pbTag[strings.Index(pbTag, "=")+1:]
-				case strings.HasPrefix(pbTag, "seq="):
-					seqStr := pbTag[strings.Index(pbTag, "=")+1:]
-					seq, _ = strconv.Atoi(seqStr)
-				case strings.HasPrefix(pbTag, "type="):
-					grpcType = pbTag[strings.Index(pbTag, "=")+1:]
-				}
+		fieldName := field.Name
+		grpcType, err := g.getGrpcType(field.Type)
+		if err != nil {
+			return fmt.Errorf("StructName:%s Field:%s %v\n %s", strc.Name, field.Name, err, field.Pos)
+		}
+
+		// 未设置seq时顺延生成序列号，并跳过保留的序列号
+		seq = gen.NextFieldNumber(seq, reserved)
+
+		// 已经在checkPBTag中检查过tag的合法性
+		tag, _ := gen.ParsePBTag(field)
+		if tag.Name != "" {
+			fieldName = tag.Name
+		}
+		if tag.Seq != 0 {
+			seq = tag.Seq
+		}
+		if tag.GrpcType != "" {
+			if strings.HasPrefix(grpcType, "optional ") {
+				grpcType = withOptional(tag.GrpcType)
+			} else {
+				grpcType = tag.GrpcType
 			}
 		}
+		if tag.Optional {
+			grpcType = withOptional(grpcType)
+		}
 
-		w.P(`%s %s = %d;`, grpcType, fieldName, seq)
+		w.P(`%s %s = %d%s;`, grpcType, fieldName, seq, fieldOptions(tag))
 		w.P(``)
 	}
 	w.P(`}`)
 	w.P(``)
+	return nil
 }
 
 func (g *ProtobufGenerator) generateEnum(strc *cst.Struct) {
 	w := NewSugerWriter(g.opts.writer)
-	w.P(`enum %s {`, strc.Name)
-	for i, c := range g.cst.Consts() {
+	w.P(`enum %s {`, g.nested.localName(strc.Name))
+	var i int
+	for _, c := range g.cst.Consts() {
 		if c.Type.Name == strc.Name {
 			w.P(``)
 			// 这里其实比较蛋疼，从grpc生成会带上PhoneType_枚举的名字前缀
 			// 如果已经带了这个前缀,这里将它去掉
+			// 嵌套的枚举带的是父message的前缀 e.g. Outer_Kind 的值为 Outer_A
 			name := strings.TrimPrefix(c.Name, strc.Name+"_")
-			w.P(`%s = %d;`, name, i)
+			if parent, found := g.nested.parentMap[strc.Name]; found {
+				name = strings.TrimPrefix(name, parent+"_")
+			}
+			// 优先使用常量定义的值，无法解析时按定义顺序编号
+			value := i
+			if v, err := strconv.Atoi(fmt.Sprint(c.Value)); err == nil {
+				value = v
+			}
+			w.P(`%s = %d;`, name, value)
+			i++
 		}
 	}
 	w.P(``)
@@ -227,75 +396,9 @@ func (g *ProtobufGenerator) generateEnum(strc *cst.Struct) {
 	w.P(``)
 }
 
-func (g *ProtobufGenerator) checkPBTag(strc *cst.Struct) {
-	var (
-		useTagCount int
-		seqMap      = map[int]cst.Field{}    // key: seq value: field
-		nameMap     = map[string]cst.Field{} // key: name value: field
-	)
-	for _, field := range strc.Fields {
-		var (
-			tag      = reflect.StructTag(field.Tag)
-			pbTagStr = tag.Get("pb")
-		)
-
-		// protobuf本身的tag(protobuf)中的数据类型不能直接作为proto中的数据类型使用
-		// 能使用的仅序列号和字段名
-		// 在这里自定义新增了一个tag(pb)用作name,seq,type的重定义
-		// 设置seq必须所有字段设置，否则会出现seq不唯一的情况
-		if field.Tag != "" && pbTagStr != "" {
-			useTagCount++
-
-			pbTags := strings.Split(pbTagStr, ",")
-			for _, pbTag := range pbTags {
-				switch {
-				case strings.HasPrefix(pbTag, "seq="):
-					seqStr := pbTag[strings.Index(pbTag, "=")+1:]
-					seq, err := strconv.Atoi(seqStr)
-					if err != nil {
-						panic(fmt.Sprintf("Unsupport grpc pb StructName:%s Field:%s tag(%s) error(%v)\n %s",
-							strc.Name, field.Name, pbTag, err, field.Pos))
-					}
-
-					field2, found := seqMap[seq]
-					if !found {
-						seqMap[seq] = field
-					} else {
-						panic(fmt.Sprintf("StructName:%s Field:%s and Field:%s have the same tag:seq(%d)\n  Field:%s %s %s\n  Field:%s %s %s",
-							strc.Name, field.Name, field2.Name, seq,
-							field.Name, field.Pos, field.Tag,
-							field2.Name, field2.Pos, field2.Tag,
-						))
-					}
-
-				case strings.HasPrefix(pbTag, "type="):
-
-				case strings.HasPrefix(pbTag, "name="):
-					name := pbTag[strings.Index(pbTag, "=")+1:]
-					field2, found := nameMap[name]
-					if !found {
-						nameMap[name] = field
-					} else {
-						panic(fmt.Sprintf("StructName:%s Field:%s and Field:%s have the same tag:name(%s)\n  Field:%s %s %s\n  Field:%s %s %s",
-							strc.Name, field.Name, field2.Name, name,
-							field.Name, field.Pos, field.Tag,
-							field2.Name, field2.Pos, field2.Tag,
-						))
-					}
-				}
-			}
-		}
-	}
-
-	// 使用了pb这个tag但是并没有给所有的字段加上，这种情况没办法增加序列号或者检查命名冲突
-	if useTagCount > 0 && useTagCount != len(strc.Fields) {
-		panic(fmt.Sprintf("If you use the \"pb\" tag you must set for(StructName:%s) all fields\n %s", strc.Name, strc.Position))
-	}
-}
-
 func (g *ProtobufGenerator) findStructInASTStructMap(pkg, structName string) (string, bool) {
 	if strc, found := g.cst.StructMap()[pkg][structName]; found {
-		return strc.Name, true
+		return g.nested.fullName(strc.Name), true
 	}
 
 	return "", false
@@ -305,7 +408,12 @@ func (g *ProtobufGenerator) GoType2GrpcType(t cst.Type) (grpcType string, found
 	goType := strings.TrimSpace(t.Name)
 	switch t.GoType {
 	case cst.BasicType:
-		return GoBasicType2GrpcType(goType)
+		grpcType, found = GoBasicType2GrpcType(goType)
+		// 基础类型的指针需要区分"未设置"和"零值"，对应proto3的optional
+		if found && t.Star {
+			grpcType = withOptional(grpcType)
+		}
+		return grpcType, found
 	case cst.ArrayType:
 		// grpc 没有单个byte的类型，特殊判断一下
 		if goType == "[]byte" {
@@ -319,10 +427,10 @@ func (g *ProtobufGenerator) GoType2GrpcType(t cst.Type) (grpcType string, found
 				return "", false
 			}
 		case cst.StructType:
-			grpcType = t.ElementType.Name
+			grpcType = g.structGrpcType(*t.ElementType)
 			found = true
 		default:
-			panic("Unsupport grpc item of array:" + t.ElementType.Name)
+			return "", false
 		}
 
 		return "repeated " + grpcType, true
@@ -338,7 +446,7 @@ func (g *ProtobufGenerator) GoType2GrpcType(t cst.Type) (grpcType string, found
 				return "", false
 			}
 		default:
-			panic("Unsupport grpc type of key of map:" + t.KeyType.Name)
+			return "", false
 		}
 
 		var valueType string
@@ -349,22 +457,96 @@ func (g *ProtobufGenerator) GoType2GrpcType(t cst.Type) (grpcType string, found
 				return "", false
 			}
 		case cst.StructType:
-			valueType = t.ValueType.Name
+			valueType = g.structGrpcType(*t.ValueType)
 			found = true
 		default:
-			panic("Unsupport grpc value of key of map:" + t.KeyType.Name)
+			return "", false
 		}
 
 		return fmt.Sprintf("map<%s, %s>", keyType, valueType), true
 	case cst.StructType:
-		return t.Name, true
-	case cst.CrossProtocolUnsupportType:
-		panic(fmt.Sprintf("This type(%s %s) is unsupport cross protocol", t.Name, t.GoType))
+		// 自定义类型为标量时，指针同样需要声明为optional
+		if c, found := g.opts.typeConverters.Find(t.BaseType); found {
+			if t.Star && c.IsScalarProtoType() {
+				return withOptional(c.ProtoType), true
+			}
+			return c.ProtoType, true
+		}
+		// message本身具有字段存在性，枚举类型的指针则需要声明为optional
+		if t.Star && g.isEnumType(t) {
+			return withOptional(g.nested.fullName(t.Name)), true
+		}
+		return g.nested.fullName(t.Name), true
 	}
 
 	return "", false
 }
 
+// 数组元素和map值中的结构体类型，优先使用自定义类型对应的proto类型
+func (g *ProtobufGenerator) structGrpcType(t cst.BaseType) string {
+	if c, found := g.opts.typeConverters.Find(t); found {
+		return c.ProtoType
+	}
+	return g.nested.fullName(t.Name)
+}
+
+// 服务中用到的自定义类型需要import的proto文件
+func (g *ProtobufGenerator) protoImports() []string {
+	var (
+		imports []string
+		exists  = map[string]struct{}{}
+	)
+	addImport := func(t cst.Type) {
+		c, found := g.opts.typeConverters.Find(t.InnermostType())
+		if !found || c.ProtoImport == "" {
+			return
+		}
+		if _, found := exists[c.ProtoImport]; found {
+			return
+		}
+		exists[c.ProtoImport] = struct{}{}
+		imports = append(imports, c.ProtoImport)
+	}
+
+	for _, strc := range g.cst.Structs() {
+		for _, field := range strc.Fields {
+			addImport(field.Type)
+		}
+	}
+	for _, i := range g.cst.Interfaces() {
+		for _, method := range i.Methods {
+			for _, field := range append(method.Params, method.Results...) {
+				addImport(field.Type)
+			}
+		}
+		// kit:http声明的路由生成google.api.http选项
+		if _, found := exists[googleAPIAnnotations]; !found && gen.HasHTTPRoute(i.Methods) {
+			exists[googleAPIAnnotations] = struct{}{}
+			imports = append(imports, googleAPIAnnotations)
+		}
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+func (g *ProtobufGenerator) isEnumType(t cst.Type) bool {
+	pkg := g.cst.PackageName()
+	if t.X != "" {
+		pkg = t.X
+	}
+	strc, found := g.cst.StructMap()[pkg][t.Name]
+	return found && strc.Type != nil
+}
+
+func withOptional(grpcType string) string {
+	if strings.HasPrefix(grpcType, "optional ") {
+		return grpcType
+	}
+	return "optional " + grpcType
+}
+
+// protobuf没有8位及16位的整数，使用能完整表示其取值范围的32位整数
+// 与thrift生成器支持的go类型保持一致，thrift中不支持uint, uint64
 func GoBasicType2GrpcType(t string) (grpcType string, found bool) {
 	goType := strings.TrimSpace(t)
 	switch goType {
@@ -372,13 +554,13 @@ func GoBasicType2GrpcType(t string) (grpcType string, found bool) {
 		return "double", true
 	case "float32":
 		return "float", true
-	case "int32":
+	case "int8", "int16", "int32":
 		return "int32", true
 	case "int", "int64":
 		return "int64", true
-	case "uint32":
+	case "uint8", "byte", "uint16", "uint32":
 		return "uint32", true
-	case "uint64":
+	case "uint", "uint64":
 		return "uint64", true
 	case "bool":
 		return "bool", true
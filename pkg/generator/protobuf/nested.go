@@ -0,0 +1,122 @@
+package protobuf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	gen "ezrpro.com/micro/kit/pkg/generator"
+)
+
+// 嵌套类型的父子关系
+// 1. 从pb.go的命名规则还原 e.g. Outer_Inner 是 message Outer 中的 Inner
+// 2. 从go源码的tag中声明 e.g. Inner Inner `pb:"nested"` Inner将在所属的message中定义
+type nestedTypes struct {
+	parentMap   map[string]string   // key: 子类型名 val: 父message名
+	childrenMap map[string][]string // key: 父message名 val: 子类型名列表
+}
+
+func parseNestedTypes(t cst.ConcreteSyntaxTree) nestedTypes {
+	var (
+		nested = nestedTypes{
+			parentMap:   map[string]string{},
+			childrenMap: map[string][]string{},
+		}
+		structMap   = t.StructMap()[t.PackageName()]
+		structNames []string
+	)
+	for name := range structMap {
+		structNames = append(structNames, name)
+	}
+	// 保证生成的顺序稳定
+	sort.Strings(structNames)
+
+	for _, name := range structNames {
+		i := strings.LastIndex(name, "_")
+		if i <= 0 {
+			continue
+		}
+		parent, found := structMap[name[:i]]
+		if found && parent.Type == nil {
+			nested.parentMap[name] = parent.Name
+		}
+	}
+
+	for _, name := range structNames {
+		strc := structMap[name]
+		for _, field := range strc.Fields {
+			if gen.IsBlankField(field) {
+				continue
+			}
+
+			tag, err := gen.ParsePBTag(field)
+			if err != nil || !tag.Nested {
+				continue
+			}
+
+			typ := field.Type.BaseType
+			if field.Type.ElementType != nil {
+				typ = *field.Type.ElementType
+			} else if field.Type.ValueType != nil {
+				typ = *field.Type.ValueType
+			}
+
+			child, found := structMap[typ.Name]
+			if typ.GoType != cst.StructType || !found || (typ.X != "" && typ.X != t.PackageName()) {
+				panic(fmt.Sprintf("StructName:%s Field:%s only message or enum of package(%s) can be nested\n %s",
+					strc.Name, field.Name, t.PackageName(), field.Pos))
+			}
+
+			if parent, found := nested.parentMap[child.Name]; found && parent != strc.Name {
+				panic(fmt.Sprintf("StructName:%s Field:%s type(%s) has been nested in %s\n %s",
+					strc.Name, field.Name, child.Name, parent, field.Pos))
+			}
+			nested.parentMap[child.Name] = strc.Name
+		}
+	}
+
+	for child, parent := range nested.parentMap {
+		// 防止 A嵌套B B又嵌套A 的情况
+		visited := map[string]struct{}{child: {}}
+		for p, found := parent, true; found; p, found = nested.parentMap[p] {
+			if _, found := visited[p]; found {
+				panic(fmt.Sprintf("StructName:%s circular nested", child))
+			}
+			visited[p] = struct{}{}
+		}
+		nested.childrenMap[parent] = append(nested.childrenMap[parent], child)
+	}
+
+	for parent := range nested.childrenMap {
+		sort.Strings(nested.childrenMap[parent])
+	}
+	return nested
+}
+
+func (n nestedTypes) isNested(name string) bool {
+	_, found := n.parentMap[name]
+	return found
+}
+
+func (n nestedTypes) children(name string) []string {
+	return n.childrenMap[name]
+}
+
+// 在父message中定义的类型名
+// Outer_Inner => Inner
+func (n nestedTypes) localName(name string) string {
+	if parent, found := n.parentMap[name]; found {
+		return strings.TrimPrefix(name, parent+"_")
+	}
+	return name
+}
+
+// 引用嵌套类型时需要使用完整的类型名
+// Outer_Inner => Outer.Inner
+func (n nestedTypes) fullName(name string) string {
+	if parent, found := n.parentMap[name]; found {
+		return n.fullName(parent) + "." + n.localName(name)
+	}
+	return name
+}
@@ -14,6 +14,7 @@ type Options struct {
 	structFilter          gen.StructFilter
 	writer                io.Writer
 	serviceSuffix         string
+	typeConverters        gen.TypeConverters
 }
 
 type Option func(*Options)
@@ -47,6 +48,8 @@ func newOptions(opts ...Option) Options {
 	if options.serviceSuffix == "" {
 		options.serviceSuffix = utils.GetServiceSuffix()
 	}
+
+	options.typeConverters = options.typeConverters.WithBuiltin()
 	return options
 }
 
@@ -85,3 +88,10 @@ func WithServiceSuffix(serviceSuffix string) Option {
 		o.serviceSuffix = serviceSuffix
 	}
 }
+
+// 自定义类型对应的proto类型 e.g. decimal.Decimal => string
+func WithTypeConverters(converters gen.TypeConverters) Option {
+	return func(o *Options) {
+		o.typeConverters = converters
+	}
+}
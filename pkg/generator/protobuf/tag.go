@@ -0,0 +1,50 @@
+package protobuf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	gen "ezrpro.com/micro/kit/pkg/generator"
+)
+
+// 字段选项 e.g. [deprecated = true, json_name = "userId"]
+func fieldOptions(t gen.PBTag) string {
+	var options []string
+	if t.Deprecated {
+		options = append(options, "deprecated = true")
+	}
+	if t.JSONName != "" {
+		options = append(options, fmt.Sprintf("json_name = %q", t.JSONName))
+	}
+	if len(options) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(options, ", ") + "]"
+}
+
+// reserved 2, 15, 9 to 11;
+// reserved "foo", "bar";
+func reservedStatements(r gen.PBReserved) []string {
+	var statements []string
+	if len(r.Ranges) > 0 {
+		var ranges []string
+		for _, rg := range r.Ranges {
+			if rg[0] == rg[1] {
+				ranges = append(ranges, strconv.Itoa(rg[0]))
+			} else {
+				ranges = append(ranges, fmt.Sprintf("%d to %d", rg[0], rg[1]))
+			}
+		}
+		statements = append(statements, fmt.Sprintf("reserved %s;", strings.Join(ranges, ", ")))
+	}
+
+	if len(r.Names) > 0 {
+		var names []string
+		for _, name := range r.Names {
+			names = append(names, strconv.Quote(name))
+		}
+		statements = append(statements, fmt.Sprintf("reserved %s;", strings.Join(names, ", ")))
+	}
+	return statements
+}
@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	"ezrpro.com/micro/kit/pkg/utils"
+)
+
+// kit:http路由的路径参数及对应的请求结构体字段
+type PathParam struct {
+	Name  string    // 路径中的参数名 e.g. id
+	Field cst.Field // 请求结构体中的字段 e.g. Id
+}
+
+// 校验方法的kit:http指令，路径参数必须对应请求结构体中基础类型的非指针字段
+// 字段名或json名不区分大小写匹配，与生成的http transport中的匹配规则一致
+// tree为合成了请求结构体的语法树
+func NewPathParams(tree cst.ConcreteSyntaxTree, method cst.Method) ([]PathParam, error) {
+	if !method.Directives.HasHTTP() {
+		return nil, nil
+	}
+
+	requestName := method.Name + utils.GetRequestSuffix()
+	request, found := tree.StructMap()[tree.PackageName()][requestName]
+	if !found {
+		return nil, fmt.Errorf("Method(%s) declares kit:http but struct(%s) is not found", method.Name, requestName)
+	}
+
+	var params []PathParam
+	for _, name := range method.Directives.HTTPPathParams() {
+		field, found := findParamField(request, name)
+		if !found {
+			return nil, fmt.Errorf("Method(%s) path parameter {%s} has no matching field in %s", method.Name, name, requestName)
+		}
+
+		// 路径参数总是存在，指针字段为nil时无法生成路径
+		if field.Type.Star {
+			return nil, fmt.Errorf("Method(%s) path parameter {%s} requires a non-pointer field, got %s", method.Name, name, field.Type.String())
+		}
+		switch getValidateKind(tree, field.Type) {
+		case validateString, validateInt, validateUint, validateFloat, validateBool:
+		default:
+			return nil, fmt.Errorf("Method(%s) path parameter {%s} requires a string, number or bool field, got %s", method.Name, name, field.Type.String())
+		}
+		params = append(params, PathParam{Name: name, Field: field})
+	}
+	return params, nil
+}
+
+// 接口中所有方法的HTTP路由，相同的请求方法及路径不能重复声明
+// e.g. GET /users/{id} 与 GET /users/{uid} 冲突
+func CheckHTTPRoutes(tree cst.ConcreteSyntaxTree, iface cst.Interface) error {
+	routes := map[string]string{}
+	for _, method := range iface.Methods {
+		if _, err := NewPathParams(tree, method); err != nil {
+			return err
+		}
+		if !method.Directives.HasHTTP() {
+			continue
+		}
+
+		var segments []string
+		for _, segment := range strings.Split(method.Directives.HTTPPath, "/") {
+			if strings.HasPrefix(segment, "{") {
+				segment = "{}"
+			}
+			segments = append(segments, segment)
+		}
+		route := method.Directives.HTTPMethod + " " + strings.Join(segments, "/")
+		if other, found := routes[route]; found {
+			return fmt.Errorf("Method(%s) and method(%s) declare the same route %s %s",
+				other, method.Name, method.Directives.HTTPMethod, method.Directives.HTTPPath)
+		}
+		routes[route] = method.Name
+	}
+	return nil
+}
+
+// 接口中是否有方法声明了kit:http
+func HasHTTPRoute(methods []cst.Method) bool {
+	for _, method := range methods {
+		if method.Directives.HasHTTP() {
+			return true
+		}
+	}
+	return false
+}
+
+func findParamField(request *cst.Struct, name string) (cst.Field, bool) {
+	for _, field := range request.Fields {
+		jsonName := strings.Split(reflect.StructTag(field.Tag).Get("json"), ",")[0]
+		if strings.EqualFold(field.Name, name) || (jsonName != "" && strings.EqualFold(jsonName, name)) {
+			return field, true
+		}
+	}
+	return cst.Field{}, false
+}
+
+// 时间间隔的Go代码 e.g. 2s => 2 * time.Second  1.5s => 1500 * time.Millisecond
+func DurationLiteral(d time.Duration) string {
+	units := []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	}
+	for _, u := range units {
+		if d%u.unit == 0 {
+			return fmt.Sprintf("%d * %s", d/u.unit, u.name)
+		}
+	}
+	return fmt.Sprintf("%d * time.Nanosecond", d)
+}
@@ -33,6 +33,7 @@ type Options struct {
 	baseServiceName   string
 	serverPackageName string
 	serviceSuffix     string
+	transportTypes    map[string]bool
 }
 
 type Option func(*Options)
@@ -46,6 +47,10 @@ func newOptions(opts ...Option) Options {
 	if options.serviceSuffix == "" {
 		options.serviceSuffix = utils.GetServiceSuffix()
 	}
+
+	if options.transportTypes == nil {
+		options.transportTypes = map[string]bool{"grpc": true, "http": true}
+	}
 	return options
 }
 
@@ -81,3 +86,14 @@ func WithServiceSuffix(serviceSuffix string) Option {
 		o.serviceSuffix = serviceSuffix
 	}
 }
+
+func WithTransportTypes(transportTypes ...string) Option {
+	return func(o *Options) {
+		if o.transportTypes == nil {
+			o.transportTypes = map[string]bool{}
+		}
+		for _, t := range transportTypes {
+			o.transportTypes[t] = true
+		}
+	}
+}
@@ -45,7 +45,7 @@ func (g *ServerGenerator) Generate() error {
 			return err
 		}
 
-		err = t.Execute(readWriter.writer, map[string]interface{}{
+		err = gen.ExecuteTemplate(t, readWriter.writer, map[string]interface{}{
 			"BaseServiceName":     g.opts.baseServiceName,
 			"PackageName":         g.opts.serverPackageName,
 			"ServiceName":         serviceIface.Name,
@@ -53,6 +53,8 @@ func (g *ServerGenerator) Generate() error {
 			"EndpointImportPath":  utils.GetEndpointImportPath(g.opts.baseServiceName),
 			"ProtobufImportPath":  utils.GetProtobufImportPath(g.opts.baseServiceName),
 			"TransportImportPath": utils.GetTransportImportPath(g.opts.baseServiceName),
+			"ThriftImportPath":    utils.GetThriftImportPath(g.opts.baseServiceName),
+			"Transports":          g.opts.transportTypes,
 		})
 		if err != nil {
 			return err
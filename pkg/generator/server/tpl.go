@@ -5,6 +5,7 @@ var DefaultServerTemplate = `
 {{$endpointPackageName := BasePath .EndpointImportPath}}
 {{$protobufPackageName := BasePath .ProtobufImportPath}}
 {{$transportPackageName := BasePath .TransportImportPath}}
+{{$thriftPackageName := BasePath .ThriftImportPath}}
 package {{.PackageName}}
 
 import (
@@ -17,7 +18,13 @@ import (
 
 	{{$servicePackageName}} "{{.ServiceImportPath}}"
         {{$endpointPackageName}} "{{.EndpointImportPath}}"
+{{if .Transports.grpc}}
         {{$protobufPackageName}} "{{.ProtobufImportPath}}"
+{{end}}
+{{if .Transports.thrift}}
+        {{$thriftPackageName}} "{{.ThriftImportPath}}"
+	"github.com/apache/thrift/lib/go/thrift"
+{{end}}
 	{{$transportPackageName}} "{{.TransportImportPath}}"
 	"ezrpro.com/micro/spiderconn"
 	"ezrpro.com/micro/spiderconn/health"
@@ -25,10 +32,16 @@ import (
 	kitgrpc "github.com/go-kit/kit/transport/grpc"
 	"github.com/oklog/oklog/pkg/group"
 	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	hv1 "google.golang.org/grpc/health/grpc_health_v1"
 )
 
+{{if .Transports.thrift}}
+// spiderconn中未定义thrift的transport类型，注册服务时使用该tag
+const transportTypeThrift = "thrift"
+{{end}}
+
 func New(opts ...Option) *group.Group {
 	options := newOptions(opts...)
 
@@ -50,6 +63,7 @@ func New(opts ...Option) *group.Group {
 		TTL:              options.ttl,
 	}
 
+{{if .Transports.grpc}}
 	if options.grpcAddr != "" {
 		if options.id == "" {
 			svc.ID = uuid.NewUUID().String()
@@ -65,6 +79,8 @@ func New(opts ...Option) *group.Group {
 		}
 	}
 
+{{end}}
+{{if .Transports.http}}
 	if options.httpAddr != "" {
 		if options.id == "" {
 			svc.ID = uuid.NewUUID().String()
@@ -79,6 +95,30 @@ func New(opts ...Option) *group.Group {
 			os.Exit(1)
 		}
 	}
+{{end}}
+{{if .Transports.thrift}}
+	if options.thriftAddr != "" {
+		if options.id == "" {
+			svc.ID = uuid.NewUUID().String()
+		}
+		svc.Tags = append(
+			options.tags,
+			[]string{options.version, transportTypeThrift}...,
+		)
+		err := addThriftServer(options, svc)
+		if err != nil {
+			options.logger.Log("err", err)
+			os.Exit(1)
+		}
+	}
+{{end}}
+	if options.metrics != nil && options.metricsAddr != "" {
+		err := addMetricsServer(options)
+		if err != nil {
+			options.logger.Log("err", err)
+			os.Exit(1)
+		}
+	}
 
 	cancelInterrupt := make(chan struct{})
 	options.group.Add(func() error {
@@ -97,6 +137,7 @@ func New(opts ...Option) *group.Group {
 	return options.group
 }
 
+{{if .Transports.grpc}}
 func addGRPCServer(options Options, svc spiderconn.Service) error {
 	var (
 		logger           log.Logger                  = options.logger
@@ -174,6 +215,9 @@ func addGRPCServer(options Options, svc spiderconn.Service) error {
 	return nil
 }
 
+{{end}}
+
+{{if .Transports.http}}
 func addHTTPServer(options Options, svc spiderconn.Service) error {
 	var (
 		logger           log.Logger                  = options.logger
@@ -225,6 +269,11 @@ func addHTTPServer(options Options, svc spiderconn.Service) error {
 		httpMux.Handle("/", transport)
 	}
 
+	// 开启监控时在http server上暴露prometheus指标
+	if options.metrics != nil {
+		httpMux.Handle("/metrics", promhttp.Handler())
+	}
+
 	errCh := make(chan error, 1)
 	group.Add(func() error {
 		if isRegister {
@@ -253,7 +302,93 @@ func addHTTPServer(options Options, svc spiderconn.Service) error {
 		errCh <- err
 	})
 	return nil
-}`
+}
+{{end}}
+
+// 单独监听metricsAddr暴露prometheus指标，用于没有http transport的服务
+func addMetricsServer(options Options) error {
+	listener, err := net.Listen("tcp", options.metricsAddr)
+	if err != nil {
+		options.logger.Log("transport", "metrics", "during", "Listen", "err", err)
+		return err
+	}
+	options.logger.Log("transport", "metrics", "addr", listener.Addr())
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	options.group.Add(func() error {
+		return http.Serve(listener, mux)
+	}, func(error) {
+		listener.Close()
+	})
+	return nil
+}
+
+{{if .Transports.thrift}}
+func addThriftServer(options Options, svc spiderconn.Service) error {
+	var (
+		logger           log.Logger                  = options.logger
+		group            *group.Group                = options.group
+		thriftAddr       string                      = options.thriftAddr
+		registrarCreator spiderconn.RegistrarCreator = options.registrarCreator
+		consulAddr       string                      = options.registrarAddress
+		transportOptions []{{$transportPackageName}}.Option       = options.transportOptions
+
+		isRegister       bool                        = registrarCreator != nil
+		registrar        sd.Registrar
+		err              error
+	)
+
+	serverTransport, err := thrift.NewTServerSocket(thriftAddr)
+	if err != nil {
+		logger.Log("transport", "Thrift", "during", "Listen", "err", err)
+		return err
+	}
+
+	// 提前监听，以便获取实际监听的地址进行注册
+	err = serverTransport.Listen()
+	if err != nil {
+		logger.Log("transport", "Thrift", "during", "Listen", "err", err)
+		return err
+	}
+	logger.Log("transport", "Thrift", "addr", serverTransport.Addr())
+
+	if isRegister {
+		registrar, err = registrarCreator(
+			consulAddr,
+			logger,
+			serverTransport.Addr().String(),
+			svc, false)
+		if err != nil {
+			logger.Log("registrar", "consul", "err", err)
+			return err
+		}
+	}
+
+	transport := {{$transportPackageName}}.NewThriftServer(transportOptions...)
+	thriftServer := thrift.NewTSimpleServer4(
+		{{$thriftPackageName}}.New{{ToCamelCase .BaseServiceName}}Processor(transport),
+		serverTransport,
+		options.thriftTransportFactory,
+		options.thriftProtocolFactory,
+	)
+
+	group.Add(func() error {
+		if isRegister {
+			registrar.Register()
+		}
+
+		return thriftServer.Serve()
+	}, func(err error) {
+		if isRegister {
+			registrar.Deregister()
+		}
+
+		thriftServer.Stop()
+	})
+	return nil
+}
+{{end}}`
 
 var DefaultOptionsTemplate = `
 {{$servicePackageName := BasePath .ServiceImportPath}}
@@ -273,6 +408,9 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/oklog/oklog/pkg/group"
 	"google.golang.org/grpc"
+{{if .Transports.thrift}}
+	"github.com/apache/thrift/lib/go/thrift"
+{{end}}
 )
 
 type Options struct {
@@ -304,6 +442,15 @@ type Options struct {
 	httpPattern string
 	httpMux     *http.ServeMux
         httpListener net.Listener
+
+	// 各个方法的监控指标，metricsAddr为空时只在http server上暴露
+	metrics     *{{$endpointPackageName}}.Metrics
+	metricsAddr string
+{{if .Transports.thrift}}
+	thriftAddr             string
+	thriftTransportFactory thrift.TTransportFactory
+	thriftProtocolFactory  thrift.TProtocolFactory
+{{end}}
 }
 
 type Option func(*Options)
@@ -342,7 +489,19 @@ func newOptions(opts ...Option) Options {
 		options.registerInterval = spiderconn.DefaultRegisterInterval
 	}
 
+{{if .Transports.thrift}}
+	if options.thriftTransportFactory == nil {
+		options.thriftTransportFactory = thrift.NewTBufferedTransportFactory(8192)
+	}
+
+	if options.thriftProtocolFactory == nil {
+		options.thriftProtocolFactory = thrift.NewTBinaryProtocolFactoryDefault()
+	}
+
+        if options.grpcAddr == "" && options.httpAddr == "" && options.thriftAddr == "" {
+{{else}}
         if options.grpcAddr == "" && options.httpAddr == "" {
+{{end}}
 		switch spiderconn.DefaultTransport {
 		case spiderconn.TransportTypeGRPC:
 			options.grpcAddr = spiderconn.DefaultServiceAddress
@@ -477,4 +636,38 @@ func WithHTTPListener(httpListener net.Listener) Option {
 		o.httpListener = httpListener
 	}
 }
+
+// WithMetrics 记录各个方法的请求数、错误数及耗时，通过/metrics暴露
+func WithMetrics(metrics *{{$endpointPackageName}}.Metrics) Option {
+	return func(o *Options) {
+		o.metrics = metrics
+		o.endpointOptions = append(o.endpointOptions, {{$endpointPackageName}}.WithMetrics(metrics))
+	}
+}
+
+// WithMetricsAddr 单独监听该地址暴露/metrics
+func WithMetricsAddr(addr string) Option {
+	return func(o *Options) {
+		o.metricsAddr = addr
+	}
+}
+{{if .Transports.thrift}}
+func WithThriftAddr(thriftAddr string) Option {
+	return func(o *Options) {
+		o.thriftAddr = thriftAddr
+	}
+}
+
+func WithThriftTransportFactory(factory thrift.TTransportFactory) Option {
+	return func(o *Options) {
+		o.thriftTransportFactory = factory
+	}
+}
+
+func WithThriftProtocolFactory(factory thrift.TProtocolFactory) Option {
+	return func(o *Options) {
+		o.thriftProtocolFactory = factory
+	}
+}
+{{end}}
 `
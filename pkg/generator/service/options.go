@@ -13,6 +13,10 @@ const (
 	BaseServiceTemplate Template = "base_service"
 	NoopServiceTemplate Template = "noop_service"
 	OptionsTemplate     Template = "options"
+	// 错误码及错误类型，transport依赖该文件
+	ErrorsTemplate Template = "errors"
+	// 记录请求日志的service middleware
+	LoggingTemplate Template = "logging"
 )
 
 var TemplateMap = map[Template]string{
@@ -20,6 +24,8 @@ var TemplateMap = map[Template]string{
 	BaseServiceTemplate: DefaultBaseServiceTemplate,
 	NoopServiceTemplate: DefaultNoopServiceTemplate,
 	OptionsTemplate:     DefaultOptionsTemplate,
+	ErrorsTemplate:      DefaultErrorsTemplate,
+	LoggingTemplate:     DefaultLoggingTemplate,
 }
 
 type Template string
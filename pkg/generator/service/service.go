@@ -87,9 +87,10 @@ func (g *ServiceGenerator) Generate() error {
 			"RequestAndResponses": reqAndResps,
 			"ReferenceStructMap":  refStructMap,
 			"ConstMap":            constMap,
+			"ErrorCodes":          gen.ErrorCodes,
 		}
 
-		err = t.Execute(readWriter.writer, data)
+		err = gen.ExecuteTemplate(t, readWriter.writer, data)
 		if err != nil {
 			return err
 		}
@@ -5,7 +5,7 @@ package {{.PackageName}}
 
 import (
         "context"
-	"errors"
+	"strconv"
 
 	"github.com/go-kit/kit/endpoint"
 )
@@ -86,10 +86,14 @@ var (
 
 {{if .InterfaceMethods}}
     {{range .InterfaceMethods}}
-// Failed implements endpoint.Failer.
+// Failed implements endpoint.Failer, the business code is kept in the details.
 func (r {{.}}Response) Failed() error {
 	if r.Code != 0 {
-		return errors.New(r.Message)
+		return &Error{
+			Code:    CodeUnknown,
+			Message: r.Message,
+			Details: map[string]string{"code": strconv.Itoa(r.Code)},
+		}
 	}
 	return nil
 }
@@ -178,3 +182,285 @@ func (n noopService) {{.}}(ctx context.Context, req *{{.}}Request) (resp *{{.}}R
     {{end}}
 {{end}}
 `
+
+var DefaultErrorsTemplate = `
+package {{.PackageName}}
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Code is the error code of {{.ServiceName}}, the values are the same as
+// gRPC codes.Code so that transports can map them to gRPC status and HTTP status.
+type Code uint32
+
+const (
+	CodeOK Code = 0{{range .ErrorCodes}}
+	// {{.Comment}}
+	Code{{.Name}} Code = {{.Value}}{{end}}
+)
+
+var codeNames = map[Code]string{
+	CodeOK: "OK",{{range .ErrorCodes}}
+	Code{{.Name}}: "{{.Name}}",{{end}}
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Code(%d)", uint32(c))
+}
+
+// ParseCode returns the Code of the name, e.g. "NotFound" => CodeNotFound.
+func ParseCode(name string) (Code, bool) {
+	for code, codeName := range codeNames {
+		if codeName == name {
+			return code, true
+		}
+	}
+	return CodeUnknown, false
+}
+
+// Error is the typed error returned by {{.ServiceName}}, it is transferred
+// by gRPC status details and HTTP problem body and decoded back in clients.
+type Error struct {
+	Code    Code
+	Message string
+	Details map[string]string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Code.String()
+	}
+	return e.Code.String() + ": " + e.Message
+}
+
+// Is reports errors with the same code as equal, so errors.Is(err, ErrNotFound)
+// matches any error created by NewNotFound.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetail returns a copy of the error with the detail added.
+func (e *Error) WithDetail(key, value string) *Error {
+	details := make(map[string]string, len(e.Details)+1)
+	for k, v := range e.Details {
+		details[k] = v
+	}
+	details[key] = value
+	return &Error{Code: e.Code, Message: e.Message, Details: details}
+}
+
+// Sentinel errors for errors.Is.
+var ({{range .ErrorCodes}}
+	Err{{.Name}} = &Error{Code: Code{{.Name}}}{{end}}
+)
+
+// NewError returns an Error with the code and the formatted message.
+func NewError(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+{{range .ErrorCodes}}
+// New{{.Name}} returns an Error with Code{{.Name}}.
+func New{{.Name}}(format string, args ...interface{}) *Error {
+	return NewError(Code{{.Name}}, format, args...)
+}
+{{end}}
+// AsError converts any error to *Error, errors without code are CodeUnknown.
+func AsError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return &Error{Code: CodeCanceled, Message: err.Error()}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Code: CodeDeadlineExceeded, Message: err.Error()}
+	}
+	return &Error{Code: CodeUnknown, Message: err.Error()}
+}
+
+// ErrorCode returns the code of err, CodeOK for nil.
+func ErrorCode(err error) Code {
+	if err == nil {
+		return CodeOK
+	}
+	return AsError(err).Code
+}
+`
+
+var DefaultLoggingTemplate = `
+{{$serviceName := .ServiceName}}
+package {{.PackageName}}
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// redacted replaces the value of fields tagged with kit:"sensitive" in logs.
+const redacted = "***"
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// LoggingMiddleware returns a Middleware that logs the method, duration, error
+// and request fields of every call, fields tagged with kit:"sensitive" are redacted.
+func LoggingMiddleware(logger log.Logger) Middleware {
+	return func(next {{.ServiceName}}) {{.ServiceName}} {
+		return loggingMiddleware{logger: logger, next: next}
+	}
+}
+
+type loggingMiddleware struct {
+	logger log.Logger
+	next   {{.ServiceName}}
+}
+{{range .InterfaceMethods}}
+// {{.}} implements {{$serviceName}}.
+func (mw loggingMiddleware) {{.}}(ctx context.Context, req *{{.}}Request) (resp *{{.}}Response, err error) {
+	defer func(begin time.Time) {
+		mw.log("{{.}}", begin, req, err)
+	}(time.Now())
+	return mw.next.{{.}}(ctx, req)
+}
+{{end}}
+func (mw loggingMiddleware) log(method string, begin time.Time, req interface{}, err error) {
+	keyvals := []interface{}{"method", method, "took", time.Since(begin)}
+	keyvals = append(keyvals, requestFields(req)...)
+	keyvals = append(keyvals, "err", err)
+	mw.logger.Log(keyvals...)
+}
+
+// requestFields returns the exported fields of the request as key values.
+func requestFields(req interface{}) []interface{} {
+	v := reflect.ValueOf(req)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var keyvals []interface{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		var value interface{} = redacted
+		if !isSensitive(field) {
+			value = redact(v.Field(i), map[uintptr]bool{})
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			value = fmt.Sprintf("%+v", value)
+		}
+		keyvals = append(keyvals, "req."+field.Name, value)
+	}
+	return keyvals
+}
+
+func isSensitive(field reflect.StructField) bool {
+	for _, opt := range strings.Split(field.Tag.Get("kit"), ",") {
+		if strings.TrimSpace(opt) == "sensitive" {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns the value with sensitive fields replaced, nested structs,
+// slices and maps are redacted recursively. visited holds the pointers on the
+// current path, so cyclic values are not followed forever.
+func redact(v reflect.Value, visited map[uintptr]bool) interface{} {
+	// Stringers are logged as is, except structs with exported fields, whose
+	// String method may print the sensitive fields.
+	if v.Type().Implements(stringerType) && !hasExportedFields(v) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if visited[v.Pointer()] {
+			return fmt.Sprintf("<cycle %s>", v.Type())
+		}
+		visited[v.Pointer()] = true
+		defer delete(visited, v.Pointer())
+		return redact(v.Elem(), visited)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem(), visited)
+	case reflect.Struct:
+		fields := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			if isSensitive(field) {
+				fields[field.Name] = redacted
+				continue
+			}
+			fields[field.Name] = redact(v.Field(i), visited)
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = redact(v.Index(i), visited)
+		}
+		return values
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		values := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			values[fmt.Sprint(key.Interface())] = redact(v.MapIndex(key), visited)
+		}
+		return values
+	}
+	return v.Interface()
+}
+
+// hasExportedFields reports whether v is a struct, or a pointer to a struct,
+// with exported fields, e.g. time.Time has none.
+func hasExportedFields(v reflect.Value) bool {
+	t := v.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
+`
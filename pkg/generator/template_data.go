@@ -56,35 +56,35 @@ func GetRequestAndResponseList(cst cst.ConcreteSyntaxTree) []ReqAndResp {
 
 func GetReferenceStructMap(tree cst.ConcreteSyntaxTree, s *cst.Struct) map[string]*cst.Struct {
 	referenceStructMap := map[string]*cst.Struct{}
+	// 入口的struct本身不放入结果中，只用来防止引用自身时重复处理
+	visited := map[string]struct{}{s.Name: {}}
+	collectReferenceStruct(tree, s, referenceStructMap, visited)
+	return referenceStructMap
+}
+
+// 递归收集struct引用的所有struct，已经处理过的struct不再递归
+// 支持自引用(A引用A)和相互引用(A引用B B又引用A)的结构体
+func collectReferenceStruct(tree cst.ConcreteSyntaxTree, s *cst.Struct, referenceStructMap map[string]*cst.Struct, visited map[string]struct{}) {
 	for _, field := range s.Fields {
-		t := field.Type
-		typ := t.BaseType
-		if t.ElementType != nil {
-			typ = *t.ElementType
-		} else if t.ValueType != nil {
-			typ = *t.ValueType
-		}
+		typ := field.Type.InnermostType()
 		// 如果当前类型是struct 递归出所有组合的struct
 		if typ.GoType != cst.StructType {
 			continue
 		}
 		for _, structMap := range tree.StructMap() {
 			strc, found := structMap[typ.Name]
-			if found {
-				_, found = referenceStructMap[strc.Name]
-				if !found {
-					referenceStructMap[strc.Name] = strc
-					// 将循环的字段名和递归入口的类型名做判断
-					// 防止死循环
-					if field.Type.Name != t.Name {
-						subStructMap := GetReferenceStructMap(tree, strc)
-						MergeStructMap(subStructMap, referenceStructMap)
-					}
-				}
+			if !found {
+				continue
+			}
+
+			if _, found = visited[strc.Name]; found {
+				continue
 			}
+			visited[strc.Name] = struct{}{}
+			referenceStructMap[strc.Name] = strc
+			collectReferenceStruct(tree, strc, referenceStructMap, visited)
 		}
 	}
-	return referenceStructMap
 }
 
 func MergeStructMap(ms ...map[string]*cst.Struct) map[string]*cst.Struct {
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"io"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/utils"
+)
+
+const (
+	ClientTemplate  Template = "client"
+	OptionsTempalte Template = "options"
+)
+
+var TemplateMap = map[Template]string{
+	ClientTemplate:  DefaultClientTemplate,
+	OptionsTempalte: DefaultOptionsTemplate,
+}
+
+type Template string
+
+func (t Template) String() string {
+	return string(t)
+}
+
+type readWriter struct {
+	writer   io.Writer
+	template io.Reader
+}
+
+type Options struct {
+	readWriterMap   map[Template]readWriter
+	baseServiceName string
+	testPackageName string
+	serviceSuffix   string
+	transportTypes  map[string]bool
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.serviceSuffix == "" {
+		options.serviceSuffix = utils.GetServiceSuffix()
+	}
+
+	if options.transportTypes == nil {
+		options.transportTypes = map[string]bool{"grpc": true, "http": true}
+	}
+	return options
+}
+
+func WithReadWriter(t Template, tpl io.Reader, w io.Writer) Option {
+	return func(o *Options) {
+		if o.readWriterMap == nil {
+			o.readWriterMap = map[Template]readWriter{}
+		}
+		if tpl == nil {
+			tpl = strings.NewReader(TemplateMap[t])
+		}
+		o.readWriterMap[t] = readWriter{
+			writer:   w,
+			template: tpl,
+		}
+	}
+}
+
+func WithBaseServiceName(baseServiceName string) Option {
+	return func(o *Options) {
+		o.baseServiceName = baseServiceName
+	}
+}
+
+func WithTestPackageName(testPackageName string) Option {
+	return func(o *Options) {
+		o.testPackageName = testPackageName
+	}
+}
+
+func WithServiceSuffix(serviceSuffix string) Option {
+	return func(o *Options) {
+		o.serviceSuffix = serviceSuffix
+	}
+}
+
+func WithTransportTypes(transportTypes ...string) Option {
+	return func(o *Options) {
+		if o.transportTypes == nil {
+			o.transportTypes = map[string]bool{}
+		}
+		for _, t := range transportTypes {
+			o.transportTypes[t] = true
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	gen "ezrpro.com/micro/kit/pkg/generator"
+	"ezrpro.com/micro/kit/pkg/utils"
+)
+
+// 生成进程内的测试工具包，service -> endpoint -> transport -> client
+// 全部在进程内连接，gRPC使用bufconn，HTTP使用httptest.Server，
+// thrift的server只能监听socket，使用本地回环地址上的随机端口
+type TestGenerator struct {
+	cst  cst.ConcreteSyntaxTree
+	opts Options
+}
+
+func NewTestGenerator(t cst.ConcreteSyntaxTree, opts ...Option) gen.Generator {
+	options := newOptions(opts...)
+
+	return &TestGenerator{
+		cst:  t,
+		opts: options,
+	}
+}
+
+func (g *TestGenerator) Generate() error {
+	for tplName, readWriter := range g.opts.readWriterMap {
+		tplBody, err := ioutil.ReadAll(readWriter.template)
+		if err != nil {
+			return err
+		}
+
+		t := template.New(string(tplName)).Funcs(map[string]interface{}{
+			"BasePath":    filepath.Base,
+			"ToCamelCase": utils.ToCamelCase,
+		})
+		t, err = t.Parse(string(tplBody))
+		if err != nil {
+			return err
+		}
+
+		serviceIface, err := gen.FilterInterface(g.cst.Interfaces(), g.opts.serviceSuffix)
+		if err != nil {
+			return err
+		}
+
+		err = gen.ExecuteTemplate(t, readWriter.writer, map[string]interface{}{
+			"BaseServiceName":     g.opts.baseServiceName,
+			"PackageName":         g.opts.testPackageName,
+			"ServiceName":         serviceIface.Name,
+			"ServiceImportPath":   utils.GetServiceImportPath(g.opts.baseServiceName),
+			"EndpointImportPath":  utils.GetEndpointImportPath(g.opts.baseServiceName),
+			"ProtobufImportPath":  utils.GetProtobufImportPath(g.opts.baseServiceName),
+			"ThriftImportPath":    utils.GetThriftImportPath(g.opts.baseServiceName),
+			"TransportImportPath": utils.GetTransportImportPath(g.opts.baseServiceName),
+			"Transports":          g.opts.transportTypes,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package test
+
+var DefaultClientTemplate = `
+{{$servicePackageName := BasePath .ServiceImportPath}}
+{{$endpointPackageName := BasePath .EndpointImportPath}}
+{{$protobufPackageName := BasePath .ProtobufImportPath}}
+{{$thriftPackageName := BasePath .ThriftImportPath}}
+{{$transportPackageName := BasePath .TransportImportPath}}
+package {{.PackageName}}
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	{{$servicePackageName}} "{{.ServiceImportPath}}"
+	{{$endpointPackageName}} "{{.EndpointImportPath}}"
+{{- if .Transports.grpc}}
+	{{$protobufPackageName}} "{{.ProtobufImportPath}}"
+{{- end}}
+{{- if .Transports.thrift}}
+	{{$thriftPackageName}} "{{.ThriftImportPath}}"
+	"github.com/apache/thrift/lib/go/thrift"
+{{- end}}
+	{{$transportPackageName}} "{{.TransportImportPath}}"
+	"ezrpro.com/micro/spiderconn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Transports supported by NewTestClient.
+const (
+{{- if .Transports.grpc}}
+	GRPC = spiderconn.TransportTypeGRPC
+{{- end}}
+{{- if .Transports.http}}
+	HTTP = spiderconn.TransportTypeHTTP
+{{- end}}
+{{- if .Transports.thrift}}
+	THRIFT = "thrift"
+{{- end}}
+)
+
+// NewTestClient serves impl through the generated endpoints and transport in
+// process and returns a client of it, so tests run the full encode/decode path
+// without registering to consul. gRPC and HTTP are served in memory, Thrift is
+// served on a random port of the loopback interface. The server and the
+// connection are closed when the test finishes.
+func NewTestClient(t testing.TB, impl {{$servicePackageName}}.{{.ServiceName}}, transport string, opts ...Option) {{$servicePackageName}}.{{.ServiceName}} {
+	t.Helper()
+	options := newOptions(opts...)
+
+	endpoints := {{$endpointPackageName}}.New(
+		append([]{{$endpointPackageName}}.Option{ {{- $endpointPackageName}}.WithService(impl)}, options.endpointOptions...)...,
+	)
+	transportOptions := append(
+		[]{{$transportPackageName}}.Option{ {{- $transportPackageName}}.WithEndpoints(endpoints)},
+		options.transportOptions...,
+	)
+
+	switch transport {
+{{- if .Transports.grpc}}
+	case GRPC:
+		return newGRPCClient(t, transportOptions, options.clientOptions)
+{{- end}}
+{{- if .Transports.http}}
+	case HTTP:
+		return newHTTPClient(t, transportOptions, options.clientOptions)
+{{- end}}
+{{- if .Transports.thrift}}
+	case THRIFT:
+		return newThriftClient(t, transportOptions, options.clientOptions)
+{{- end}}
+	}
+	t.Fatalf("{{.PackageName}}: unsupported transport %q", transport)
+	return nil
+}
+{{if .Transports.grpc}}
+const bufSize = 1024 * 1024
+
+func newGRPCClient(t testing.TB, transportOptions []{{$transportPackageName}}.Option, clientOptions []{{$transportPackageName}}.ClientOption) {{$servicePackageName}}.{{.ServiceName}} {
+	listener := bufconn.Listen(bufSize)
+	server := grpc.NewServer()
+	{{$protobufPackageName}}.Register{{ToCamelCase .BaseServiceName}}Server(server, {{$transportPackageName}}.NewGRPCServer(transportOptions...))
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		server.Stop()
+		t.Fatalf("{{.PackageName}}: dial bufconn: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+
+	return {{$transportPackageName}}.NewGRPCClient(conn, clientOptions...)
+}
+{{end}}
+{{if .Transports.http}}
+func newHTTPClient(t testing.TB, transportOptions []{{$transportPackageName}}.Option, clientOptions []{{$transportPackageName}}.ClientOption) {{$servicePackageName}}.{{.ServiceName}} {
+	server := httptest.NewServer({{$transportPackageName}}.NewHTTPHandler(transportOptions...))
+	t.Cleanup(server.Close)
+
+	client, err := {{$transportPackageName}}.NewHTTPClient(server.URL, clientOptions...)
+	if err != nil {
+		t.Fatalf("{{.PackageName}}: new http client: %v", err)
+	}
+	return client
+}
+{{end}}
+{{if .Transports.thrift}}
+func newThriftClient(t testing.TB, transportOptions []{{$transportPackageName}}.Option, clientOptions []{{$transportPackageName}}.ClientOption) {{$servicePackageName}}.{{.ServiceName}} {
+	transportFactory := thrift.NewTBufferedTransportFactory(8192)
+	protocolFactory := thrift.NewTBinaryProtocolFactoryDefault()
+
+	// Thrift servers only serve on sockets, listen on a random port.
+	serverSocket, err := thrift.NewTServerSocket("127.0.0.1:0")
+	if err == nil {
+		err = serverSocket.Listen()
+	}
+	if err != nil {
+		t.Fatalf("{{.PackageName}}: listen thrift: %v", err)
+	}
+	server := thrift.NewTSimpleServer4(
+		{{$thriftPackageName}}.New{{ToCamelCase .BaseServiceName}}Processor({{$transportPackageName}}.NewThriftServer(transportOptions...)),
+		serverSocket,
+		transportFactory,
+		protocolFactory,
+	)
+	go server.Serve()
+
+	socket, err := thrift.NewTSocket(serverSocket.Addr().String())
+	if err != nil {
+		server.Stop()
+		t.Fatalf("{{.PackageName}}: new thrift socket: %v", err)
+	}
+	transport, err := transportFactory.GetTransport(socket)
+	if err == nil {
+		err = transport.Open()
+	}
+	if err != nil {
+		server.Stop()
+		t.Fatalf("{{.PackageName}}: open thrift transport: %v", err)
+	}
+	t.Cleanup(func() {
+		transport.Close()
+		server.Stop()
+	})
+
+	client := thrift.NewTStandardClient(protocolFactory.GetProtocol(transport), protocolFactory.GetProtocol(transport))
+	return {{$transportPackageName}}.NewThriftClient({{$thriftPackageName}}.New{{ToCamelCase .BaseServiceName}}Client(client), clientOptions...)
+}
+{{end}}
+`
+
+var DefaultOptionsTemplate = `
+{{$endpointPackageName := BasePath .EndpointImportPath}}
+{{$transportPackageName := BasePath .TransportImportPath}}
+package {{.PackageName}}
+
+import (
+	{{$endpointPackageName}} "{{.EndpointImportPath}}"
+	{{$transportPackageName}} "{{.TransportImportPath}}"
+)
+
+type Options struct {
+	endpointOptions  []{{$endpointPackageName}}.Option
+	transportOptions []{{$transportPackageName}}.Option
+	clientOptions    []{{$transportPackageName}}.ClientOption
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithEndpointOptions e.g. middlewares of the server side endpoints.
+func WithEndpointOptions(opts ...{{$endpointPackageName}}.Option) Option {
+	return func(o *Options) {
+		o.endpointOptions = append(o.endpointOptions, opts...)
+	}
+}
+
+func WithTransportOptions(opts ...{{$transportPackageName}}.Option) Option {
+	return func(o *Options) {
+		o.transportOptions = append(o.transportOptions, opts...)
+	}
+}
+
+func WithClientOptions(opts ...{{$transportPackageName}}.ClientOption) Option {
+	return func(o *Options) {
+		o.clientOptions = append(o.clientOptions, opts...)
+	}
+}
+`
@@ -0,0 +1,421 @@
+package thrift
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	gen "ezrpro.com/micro/kit/pkg/generator"
+	"ezrpro.com/micro/kit/pkg/generator/protobuf"
+	"ezrpro.com/micro/kit/pkg/generator/service"
+	"ezrpro.com/micro/kit/pkg/utils"
+)
+
+const (
+	// thrift的字段序列号是i16类型
+	maxFieldID = 1<<15 - 1
+)
+
+type ThriftGenerator struct {
+	cst           cst.ConcreteSyntaxTree
+	opts          Options
+	referenceType map[string]struct{} // key: [struct.Name or type.Name] val: struct{}{}
+}
+
+func NewThriftGenerator(t cst.ConcreteSyntaxTree, opts ...Option) gen.Generator {
+	options := newOptions(opts...)
+
+	return &ThriftGenerator{
+		cst:           t,
+		opts:          options,
+		referenceType: map[string]struct{}{},
+	}
+}
+
+func (g *ThriftGenerator) Generate() error {
+	// 非标准签名的方法使用合成的请求及响应结构体
+	tree, err := gen.NewEnvelopeTree(g.cst, g.opts.serviceSuffix)
+	if err != nil {
+		return err
+	}
+	g.cst = tree
+
+	baseServiceName := service.GetBaseServiceName(g.cst.PackageName(), g.opts.serviceSuffix)
+	thriftPath := utils.GetThriftFilePath(baseServiceName)
+	thriftPackageName := filepath.Base(thriftPath)
+	w := protobuf.NewSugerWriter(g.opts.writer)
+	w.P("namespace go %s", thriftPackageName)
+	w.P(``)
+	w.P(``)
+
+	// thrift中类型需要先定义再使用，service放在最后生成
+	// 这里先找出service中引用的所有类型
+	for _, i := range g.cst.Interfaces() {
+		for _, method := range i.Methods {
+			for _, field := range append(method.Params, method.Results...) {
+				if g.opts.typeFilter(field.Type) {
+					continue
+				}
+				g.recursiveFieldType(field.Type)
+			}
+		}
+	}
+
+	// 枚举需要在使用它的struct之前定义
+	for _, strc := range g.cst.Structs() {
+		if strc.Type == nil || !g.isGenerateStruct(strc) {
+			continue
+		}
+		g.generateEnum(strc)
+	}
+
+	for _, strc := range g.sortStructs() {
+		if err := g.generateStruct(strc); err != nil {
+			return err
+		}
+	}
+
+	for _, i := range g.cst.Interfaces() {
+		if err := g.generateInterface(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 跳过制定过滤的struct 和 未使用的struct
+func (g *ThriftGenerator) isGenerateStruct(strc *cst.Struct) bool {
+	if !g.opts.structFilter(strc) {
+		return true
+	}
+	_, found := g.referenceType[strc.Name]
+	return found
+}
+
+// 按照依赖关系排序，被引用的struct先于引用它的struct定义
+func (g *ThriftGenerator) sortStructs() []*cst.Struct {
+	var (
+		sorted  []*cst.Struct
+		visited = map[string]struct{}{}
+		visit   func(strc *cst.Struct)
+	)
+	visit = func(strc *cst.Struct) {
+		if _, found := visited[strc.Name]; found {
+			return
+		}
+		visited[strc.Name] = struct{}{}
+		for _, field := range strc.Fields {
+			typ := field.Type.InnermostType()
+			if typ.GoType != cst.StructType {
+				continue
+			}
+			pkg := strc.PackageName
+			if typ.X != "" {
+				pkg = typ.X
+			}
+			if dep, found := g.cst.StructMap()[pkg][typ.Name]; found && dep.Type == nil {
+				visit(dep)
+			}
+		}
+		sorted = append(sorted, strc)
+	}
+
+	for _, strc := range g.cst.Structs() {
+		if strc.Type != nil || !g.isGenerateStruct(strc) {
+			continue
+		}
+		visit(strc)
+	}
+	return sorted
+}
+
+func (g *ThriftGenerator) recursiveFieldType(t cst.Type) {
+	typ := t.InnermostType()
+	// 如果当前类型是struct 递归出所有组合的struct
+	if typ.GoType != cst.StructType {
+		return
+	}
+	for _, structMap := range g.cst.StructMap() {
+		strc, found := structMap[typ.Name]
+		if found {
+			_, found = g.referenceType[strc.Name]
+			if !found {
+				g.referenceType[strc.Name] = struct{}{}
+				for _, field := range strc.Fields {
+					g.recursiveFieldType(field.Type)
+				}
+			}
+		}
+	}
+}
+
+func (g *ThriftGenerator) generateInterface(i cst.Interface) error {
+	w := protobuf.NewSugerWriter(g.opts.writer)
+	serviceName := g.opts.serviceNameNormalizer.Normalize(i.Name)
+	w.P(`service %s {`, serviceName)
+	w.P(``)
+	for _, method := range i.Methods {
+		if err := g.generateServiceMethod(method); err != nil {
+			return err
+		}
+	}
+	w.P(`}`)
+	w.P(``)
+	return nil
+}
+
+// SumResponse Sum(1: SumRequest req),
+func (g *ThriftGenerator) generateServiceMethod(method cst.Method) error {
+	w := protobuf.NewSugerWriter(g.opts.writer)
+
+	resultType := "void"
+	for _, field := range method.Results {
+		if g.opts.typeFilter(field.Type) {
+			continue
+		}
+		thriftType, err := g.getThriftType(field.Type)
+		if err != nil {
+			return err
+		}
+		resultType = thriftType
+		break
+	}
+
+	var params []string
+	for _, field := range method.Params {
+		if g.opts.typeFilter(field.Type) {
+			continue
+		}
+		thriftType, err := g.getThriftType(field.Type)
+		if err != nil {
+			return err
+		}
+		name := field.Name
+		if name == "" {
+			name = "req"
+		}
+		params = append(params, fmt.Sprintf("%d: %s %s", len(params)+1, thriftType, name))
+	}
+
+	w.P(`%s %s(%s),`, resultType, method.Name, strings.Join(params, ", "))
+	w.P(``)
+	return nil
+}
+
+func (g *ThriftGenerator) generateStruct(strc *cst.Struct) error {
+	w := protobuf.NewSugerWriter(g.opts.writer)
+
+	// 和protobuf使用相同的pb tag规则，保证两种IDL的字段序列号一致
+	reserved, err := gen.CheckPBTag(strc)
+	if err != nil {
+		return err
+	}
+
+	w.P(`struct %s {`, strc.Name)
+	w.P(``)
+
+	var seq int
+	for _, field := range strc.Fields {
+		// 空白字段仅用于声明结构体级别的pb tag
+		if gen.IsBlankField(field) {
+			continue
+		}
+
+		thriftType, err := g.getThriftType(field.Type)
+		if err != nil {
+			return fmt.Errorf("StructName:%s Field:%s %v\n %s", strc.Name, field.Name, err, field.Pos)
+		}
+
+		seq = gen.NextFieldNumber(seq, reserved)
+
+		tag, _ := gen.ParsePBTag(field)
+		fieldName := field.Name
+		if tag.Name != "" {
+			fieldName = tag.Name
+		}
+		if tag.Seq != 0 {
+			seq = tag.Seq
+		}
+		if seq > maxFieldID {
+			return fmt.Errorf("StructName:%s Field:%s field id(%d) of thrift must be less than %d\n %s",
+				strc.Name, field.Name, seq, maxFieldID, field.Pos)
+		}
+
+		// 基础类型的指针需要区分"未设置"和"零值"，对应thrift的optional
+		var requiredness string
+		if tag.Optional || (field.Type.Star && field.Type.GoType != cst.StructType) || g.isEnumPointer(field.Type) {
+			requiredness = "optional "
+		}
+
+		w.P(`%d: %s%s %s%s,`, seq, requiredness, thriftType, fieldName, fieldAnnotations(tag))
+		w.P(``)
+	}
+	w.P(`}`)
+	w.P(``)
+	return nil
+}
+
+// (deprecated = "true", json_name = "userId")
+func fieldAnnotations(t gen.PBTag) string {
+	var annotations []string
+	if t.Deprecated {
+		annotations = append(annotations, `deprecated = "true"`)
+	}
+	if t.JSONName != "" {
+		annotations = append(annotations, fmt.Sprintf("json_name = %q", t.JSONName))
+	}
+	if len(annotations) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(annotations, ", ") + ")"
+}
+
+func (g *ThriftGenerator) generateEnum(strc *cst.Struct) {
+	w := protobuf.NewSugerWriter(g.opts.writer)
+	w.P(`enum %s {`, strc.Name)
+
+	// pb.go中嵌套的枚举带的是父message的前缀 e.g. Outer_Kind 的值为 Outer_A
+	var parentPrefix string
+	if i := strings.LastIndex(strc.Name, "_"); i > 0 {
+		if _, found := g.cst.StructMap()[strc.PackageName][strc.Name[:i]]; found {
+			parentPrefix = strc.Name[:i] + "_"
+		}
+	}
+
+	var i int
+	for _, c := range g.cst.Consts() {
+		if c.Type.Name == strc.Name {
+			w.P(``)
+			name := strings.TrimPrefix(c.Name, strc.Name+"_")
+			if parentPrefix != "" {
+				name = strings.TrimPrefix(name, parentPrefix)
+			}
+			// 优先使用常量定义的值，无法解析时按定义顺序编号
+			value := i
+			if v, err := strconv.Atoi(fmt.Sprint(c.Value)); err == nil {
+				value = v
+			}
+			w.P(`%s = %d,`, name, value)
+			i++
+		}
+	}
+	w.P(``)
+	w.P(`}`)
+	w.P(``)
+}
+
+func (g *ThriftGenerator) isEnumPointer(t cst.Type) bool {
+	if !t.Star || t.GoType != cst.StructType {
+		return false
+	}
+	pkg := g.cst.PackageName()
+	if t.X != "" {
+		pkg = t.X
+	}
+	strc, found := g.cst.StructMap()[pkg][t.Name]
+	return found && strc.Type != nil
+}
+
+func (g *ThriftGenerator) getThriftType(t cst.Type) (string, error) {
+	thriftType, found := g.GoType2ThriftType(t)
+	if !found {
+		if strings.Contains(t.String(), "uint") {
+			return "", fmt.Errorf("Not found (%s) in thrift type mapping, thrift has no unsigned integer to hold uint and uint64, use int64 or string instead", t.String())
+		}
+		return "", fmt.Errorf("Not found (%s) in thrift type mapping", t.String())
+	}
+	return thriftType, nil
+}
+
+func (g *ThriftGenerator) GoType2ThriftType(t cst.Type) (thriftType string, found bool) {
+	goType := strings.TrimSpace(t.Name)
+	switch t.GoType {
+	case cst.BasicType:
+		return GoBasicType2ThriftType(goType)
+	case cst.ArrayType:
+		if goType == "[]byte" {
+			return "binary", true
+		}
+
+		elemType, found := g.baseType2ThriftType(*t.ElementType)
+		if !found {
+			return "", false
+		}
+		return fmt.Sprintf("list<%s>", elemType), true
+	case cst.MapType:
+		// thrift的map key虽然没有类型限制，但是为了和protobuf保持一致，只支持基础类型
+		if t.KeyType.GoType != cst.BasicType {
+			return "", false
+		}
+		keyType, found := GoBasicType2ThriftType(t.KeyType.Name)
+		if !found {
+			return "", false
+		}
+
+		valueType, found := g.baseType2ThriftType(*t.ValueType)
+		if !found {
+			return "", false
+		}
+		return fmt.Sprintf("map<%s, %s>", keyType, valueType), true
+	case cst.StructType:
+		return g.structType2ThriftType(t.BaseType)
+	case cst.CrossProtocolUnsupportType:
+		panic(fmt.Sprintf("This type(%s %s) is unsupport cross protocol", t.Name, t.GoType))
+	}
+
+	return "", false
+}
+
+func (g *ThriftGenerator) baseType2ThriftType(t cst.BaseType) (string, bool) {
+	switch t.GoType {
+	case cst.BasicType:
+		return GoBasicType2ThriftType(t.Name)
+	case cst.StructType:
+		return g.structType2ThriftType(t)
+	case cst.ArrayType, cst.MapType:
+		// 嵌套的集合类型 e.g. list<list<i64>>
+		return g.GoType2ThriftType(t.FullType())
+	}
+	return "", false
+}
+
+func (g *ThriftGenerator) structType2ThriftType(t cst.BaseType) (string, bool) {
+	pkg := g.cst.PackageName()
+	// 尝试从type所在的包查找
+	if t.X != "" {
+		pkg = t.X
+	}
+
+	strc, found := g.cst.StructMap()[pkg][t.Name]
+	if !found {
+		return "", false
+	}
+	return strc.Name, true
+}
+
+// thrift没有无符号整数，uint8, uint16, uint32使用能完整表示其取值范围的有符号整数
+// uint, uint64没有能完整表示其取值范围的类型，不支持转换，需要声明为int64或者string
+// 与protobuf生成器支持的go类型保持一致，protobuf中uint, uint64对应uint64
+func GoBasicType2ThriftType(t string) (thriftType string, found bool) {
+	goType := strings.TrimSpace(t)
+	switch goType {
+	case "float64", "float32":
+		return "double", true
+	case "int8":
+		return "byte", true
+	case "int16", "uint8", "byte":
+		return "i16", true
+	case "int32", "uint16":
+		return "i32", true
+	case "int", "int64", "uint32":
+		return "i64", true
+	case "bool":
+		return "bool", true
+	case "string":
+		return "string", true
+	}
+	return "", false
+}
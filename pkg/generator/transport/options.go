@@ -4,19 +4,28 @@ import (
 	"io"
 	"strings"
 
+	gen "ezrpro.com/micro/kit/pkg/generator"
+	"ezrpro.com/micro/kit/pkg/generator/assignment"
 	"ezrpro.com/micro/kit/pkg/utils"
 )
 
 const (
 	GRPCTemplate    Template = "grpc"
 	HTTPTemplate    Template = "http"
-	OptionsTempalte Template = "options"
+	ThriftTemplate  Template = "thrift"
+	ConvertTemplate Template = "convert"
+	// 可选生成的转换方法往返测试
+	ConvertTestTemplate Template = "convert_test"
+	OptionsTempalte     Template = "options"
 )
 
 var TemplateMap = map[Template]string{
-	GRPCTemplate:    DefaultGRPCTemplate,
-	HTTPTemplate:    DefaultHTTPTemplate,
-	OptionsTempalte: DefaultOptionsTemplate,
+	GRPCTemplate:        DefaultGRPCTemplate,
+	HTTPTemplate:        DefaultHTTPTemplate,
+	ThriftTemplate:      DefaultThriftTemplate,
+	ConvertTemplate:     DefaultConvertTemplate,
+	ConvertTestTemplate: DefaultConvertTestTemplate,
+	OptionsTempalte:     DefaultOptionsTemplate,
 }
 
 type Template string
@@ -36,6 +45,12 @@ type Options struct {
 	baseServiceName      string
 	serviceSuffix        string
 	pbGoPath             string
+	thriftGoPath         string
+	matchStrategy        assignment.MatchStrategy
+	enumUnknown          assignment.EnumUnknownBehaviour
+	strict               bool // 存在没有被赋值的字段时生成失败
+	typeConverters       gen.TypeConverters
+	uncheckedNarrowing   bool // 数值收窄时不检查取值范围
 }
 
 type Option func(*Options)
@@ -50,6 +65,7 @@ func newOptions(opts ...Option) Options {
 		options.serviceSuffix = utils.GetServiceSuffix()
 	}
 
+	options.typeConverters = options.typeConverters.WithBuiltin()
 	return options
 }
 
@@ -91,3 +107,43 @@ func WithPBGoPath(pbGoPath string) Option {
 		o.pbGoPath = pbGoPath
 	}
 }
+
+func WithThriftGoPath(thriftGoPath string) Option {
+	return func(o *Options) {
+		o.thriftGoPath = thriftGoPath
+	}
+}
+
+// 转换时service和pb之间字段的匹配策略
+func WithMatchStrategy(strategy assignment.MatchStrategy) Option {
+	return func(o *Options) {
+		o.matchStrategy = strategy
+	}
+}
+
+// 枚举转换时遇到未定义的枚举值的处理方式
+func WithEnumUnknown(behaviour assignment.EnumUnknownBehaviour) Option {
+	return func(o *Options) {
+		o.enumUnknown = behaviour
+	}
+}
+
+func WithStrict(strict bool) Option {
+	return func(o *Options) {
+		o.strict = strict
+	}
+}
+
+// 自定义类型的转换方法 e.g. decimal.Decimal <=> string
+func WithTypeConverters(converters gen.TypeConverters) Option {
+	return func(o *Options) {
+		o.typeConverters = converters
+	}
+}
+
+// 数值收窄时不检查取值范围 e.g. int64 => int32直接截断
+func WithUncheckedNarrowing(unchecked bool) Option {
+	return func(o *Options) {
+		o.uncheckedNarrowing = unchecked
+	}
+}
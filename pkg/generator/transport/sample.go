@@ -0,0 +1,292 @@
+package transport
+
+import (
+	"fmt"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+	gen "ezrpro.com/micro/kit/pkg/generator"
+	"ezrpro.com/micro/kit/pkg/generator/assignment"
+)
+
+const (
+	// 递归引用的结构体只向下填充有限的层数
+	maxSampleDepth = 3
+)
+
+// convert_test.go中一个请求或响应的往返测试数据
+type roundTrip struct {
+	Name       string      // 结构体名 e.g. SumRequest
+	Sample     string      // 填充了所有字段的结构体字面量 e.g. &addservice.SumRequest{A: 1, ...}
+	FuzzParams []fuzzParam // 作为fuzz参数的基础类型字段
+}
+
+// fuzz测试参数，覆盖sample中对应的字段
+type fuzzParam struct {
+	Field string // 字段名
+	Var   string // 参数名
+	Type  string // 参数类型
+	Seed  string // 种子值 e.g. int64(1)
+	Star  bool   // 字段是否为指针
+}
+
+// 根据service和pb的语法树生成测试数据
+// 只填充能够在两端之间互相转换的字段，保证往返之后数据一致
+type sampleGenerator struct {
+	cst        cst.ConcreteSyntaxTree
+	pbcst      cst.ConcreteSyntaxTree
+	factory    *assignment.GeneratorFactory
+	converters gen.TypeConverters
+}
+
+func newSampleGenerator(t, pbcst cst.ConcreteSyntaxTree, factory *assignment.GeneratorFactory, converters gen.TypeConverters) *sampleGenerator {
+	return &sampleGenerator{
+		cst:        t,
+		pbcst:      pbcst,
+		factory:    factory,
+		converters: converters,
+	}
+}
+
+// service和pb中都存在的请求和响应
+func (g *sampleGenerator) roundTrips() []roundTrip {
+	var (
+		roundTrips []roundTrip
+		pbList     = gen.GetRequestAndResponseList(g.pbcst)
+	)
+	for _, rr := range gen.GetRequestAndResponseList(g.cst) {
+		for _, s := range []*cst.Struct{rr.Request, rr.Response} {
+			if s == nil {
+				continue
+			}
+			pbStruct := findPBStruct(pbList, s.Name)
+			if pbStruct == nil {
+				continue
+			}
+			roundTrips = append(roundTrips, roundTrip{
+				Name:       s.Name,
+				Sample:     "&" + g.structLiteral(s, pbStruct, 0),
+				FuzzParams: g.fuzzParams(s, pbStruct),
+			})
+		}
+	}
+	return roundTrips
+}
+
+func findPBStruct(list []gen.ReqAndResp, name string) *cst.Struct {
+	for _, rr := range list {
+		if rr.Request != nil && rr.Request.Name == name {
+			return rr.Request
+		}
+		if rr.Response != nil && rr.Response.Name == name {
+			return rr.Response
+		}
+	}
+	return nil
+}
+
+// 能够往返转换的字段 e.g. service.UserID <=> pb.UserId
+func (g *sampleGenerator) mappedFields(s, pbStruct *cst.Struct) [][2]cst.Field {
+	var fields [][2]cst.Field
+	for _, field := range s.Fields {
+		if gen.IsBlankField(field) || field.Name == "" || strings.ToUpper(field.Name[:1]) != field.Name[:1] {
+			continue
+		}
+		// decode: pb => service
+		pbField, found := g.factory.MatchField(pbStruct, s, field)
+		if !found {
+			continue
+		}
+		// encode: service => pb
+		back, found := g.factory.MatchField(s, pbStruct, pbField)
+		if !found || back.Name != field.Name {
+			continue
+		}
+		fields = append(fields, [2]cst.Field{field, pbField})
+	}
+	return fields
+}
+
+// addservice.SumRequest{A: 1, Tags: []string{"Tags"}, ...}
+func (g *sampleGenerator) structLiteral(s, pbStruct *cst.Struct, depth int) string {
+	var values []string
+	for _, pair := range g.mappedFields(s, pbStruct) {
+		value, ok := g.value(pair[0].Name, pair[0].Type, pair[1].Type, s.PackageName, depth)
+		if !ok {
+			continue
+		}
+		values = append(values, fmt.Sprintf("%s: %s", pair[0].Name, value))
+	}
+	return fmt.Sprintf("%s.%s{%s}", s.PackageName, s.Name, strings.Join(values, ", "))
+}
+
+// 生成字段的样例值，无法生成时返回false，该字段保持零值
+func (g *sampleGenerator) value(name string, t, pbType cst.Type, pkg string, depth int) (string, bool) {
+	switch t.GoType {
+	case cst.BasicType:
+		value, ok := basicSample(name, t.Name)
+		if !ok {
+			return "", false
+		}
+		if t.Star {
+			return fmt.Sprintf("func() *%s { v := %s(%s); return &v }()", t.Name, t.Name, value), true
+		}
+		return value, true
+	case cst.StructType:
+		// 自定义转换的类型使用配置的样例值 e.g. time.Time, decimal.Decimal
+		if c, found := g.converters.Find(t.BaseType); found {
+			if c.Sample == "" {
+				return "", false
+			}
+			if t.Star {
+				return fmt.Sprintf("func() *%s { v := %s; return &v }()", c.GoType, c.Sample), true
+			}
+			return c.Sample, true
+		}
+
+		s := g.findStruct(t.BaseType, pkg, g.cst)
+		pbStruct := g.findStruct(pbType.BaseType, g.pbcst.PackageName(), g.pbcst)
+		if s == nil || pbStruct == nil {
+			return "", false
+		}
+		// 枚举使用能够往返转换的非零成员
+		if s.Type != nil {
+			member, ok := g.factory.EnumSample(s, pbStruct)
+			if !ok {
+				return "", false
+			}
+			if t.Star {
+				return fmt.Sprintf("func() *%s.%s { v := %s; return &v }()", s.PackageName, s.Name, member), true
+			}
+			return member, true
+		}
+		if depth >= maxSampleDepth {
+			return "", false
+		}
+		literal := g.structLiteral(s, pbStruct, depth+1)
+		if t.Star {
+			return "&" + literal, true
+		}
+		return literal, true
+	case cst.ArrayType:
+		if t.ElementType == nil || pbType.ElementType == nil {
+			return "", false
+		}
+		if t.ElementType.GoType == cst.BasicType && t.ElementType.Name == "byte" {
+			return fmt.Sprintf("[]byte(%q)", name), true
+		}
+		typeName, ok := g.typeName(t, pkg)
+		if !ok {
+			return "", false
+		}
+		elem, ok := g.value(name, t.ElementType.FullType(), pbType.ElementType.FullType(), pkg, depth+1)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("%s{%s}", typeName, elem), true
+	case cst.MapType:
+		if t.KeyType == nil || t.ValueType == nil || pbType.ValueType == nil {
+			return "", false
+		}
+		typeName, ok := g.typeName(t, pkg)
+		if !ok {
+			return "", false
+		}
+		key, ok := basicSample(name, t.KeyType.Name)
+		if !ok {
+			return "", false
+		}
+		value, ok := g.value(name, t.ValueType.FullType(), pbType.ValueType.FullType(), pkg, depth+1)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("%s{%s: %s}", typeName, key, value), true
+	}
+	return "", false
+}
+
+func (g *sampleGenerator) findStruct(t cst.BaseType, pkg string, tree cst.ConcreteSyntaxTree) *cst.Struct {
+	if t.X != "" {
+		pkg = t.X
+	}
+	return tree.StructMap()[pkg][t.Name]
+}
+
+// 带包名的类型名 e.g. []*addservice.Geo map[string][]int
+func (g *sampleGenerator) typeName(t cst.Type, pkg string) (string, bool) {
+	switch t.GoType {
+	case cst.BasicType:
+		return t.String(), true
+	case cst.StructType:
+		name := ""
+		if c, found := g.converters.Find(t.BaseType); found {
+			name = c.GoType
+		} else if s := g.findStruct(t.BaseType, pkg, g.cst); s != nil {
+			name = s.PackageName + "." + s.Name
+		} else {
+			return "", false
+		}
+		if t.Star {
+			return "*" + name, true
+		}
+		return name, true
+	case cst.ArrayType:
+		if t.ElementType == nil {
+			return "", false
+		}
+		elem, ok := g.typeName(t.ElementType.FullType(), pkg)
+		return "[]" + elem, ok
+	case cst.MapType:
+		if t.KeyType == nil || t.ValueType == nil {
+			return "", false
+		}
+		value, ok := g.typeName(t.ValueType.FullType(), pkg)
+		return fmt.Sprintf("map[%s]%s", t.KeyType.String(), value), ok
+	}
+	return "", false
+}
+
+// 基础类型的样例值，字符串使用字段名便于定位不一致的字段
+func basicSample(name, typeName string) (string, bool) {
+	switch typeName {
+	case "string":
+		return fmt.Sprintf("%q", name), true
+	case "bool":
+		return "true", true
+	case "float32", "float64":
+		return "1.5", true
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "byte", "rune":
+		return "1", true
+	}
+	return "", false
+}
+
+// 顶层的基础类型字段作为fuzz参数
+// 浮点数的NaN无法通过DeepEqual比较，不作为fuzz参数
+// 转换为pb时收窄的字段 e.g. int64 => int32 超出范围的值无法往返，不作为fuzz参数
+func (g *sampleGenerator) fuzzParams(s, pbStruct *cst.Struct) []fuzzParam {
+	var params []fuzzParam
+	for _, pair := range g.mappedFields(s, pbStruct) {
+		field, pbField := pair[0], pair[1]
+		if field.Type.GoType != cst.BasicType ||
+			assignment.IsNarrowing(field.Type.Name, pbField.Type.Name) {
+			continue
+		}
+		seed, ok := basicSample(field.Name, field.Type.Name)
+		if !ok || strings.HasPrefix(field.Type.Name, "float") {
+			continue
+		}
+		if field.Type.Name != "string" && field.Type.Name != "bool" {
+			seed = fmt.Sprintf("%s(%s)", field.Type.Name, seed)
+		}
+		params = append(params, fuzzParam{
+			Field: field.Name,
+			Var:   fmt.Sprintf("p%d", len(params)),
+			Type:  field.Type.Name,
+			Seed:  seed,
+			Star:  field.Type.Star,
+		})
+	}
+	return params
+}
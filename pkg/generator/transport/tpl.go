@@ -13,8 +13,13 @@ import (
 	"context"
 	"errors"
 
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/tracing/opentracing"
 	grpctransport "github.com/go-kit/kit/transport/grpc"
 
@@ -22,6 +27,9 @@ import (
         {{$endpointPackageName}} "{{.EndpointImportPath}}"
         {{$protobufPackageName}} "{{.ProtobufImportPath}}"
         "ezrpro.com/micro/spiderconn"
+        {{range .TypeConverterImports}}
+        "{{.}}"
+        {{- end}}
 )
 
 type grpcServer struct {
@@ -59,7 +67,7 @@ func NewGRPCServer(opts ...Option) {{.ProtobufCST.PackageName}}.{{.ProtobufCST.S
 func (s *grpcServer) {{$method.Name}}(ctx context.Context, req *{{$protobufPackageName}}.{{$method.Name}}Request) (*{{$protobufPackageName}}.{{$method.Name}}Response, error) {
 	_, resp, err := s.{{ToLowerFirstCamelCase $method.Name}}.ServeGRPC(ctx, req)
 	if err != nil {
-		return nil, err
+		return nil, encodeGRPCError(err)
 	}
 	return resp.(*{{$protobufPackageName}}.{{$method.Name}}Response), nil
 }
@@ -89,6 +97,7 @@ func NewGRPCClient(conn *grpc.ClientConn, opts ...ClientOption) {{$servicePackag
 			{{$protobufPackageName}}.{{$method.Name}}Response{},
 			append(options.clientOptions, grpctransport.ClientBefore(opentracing.ContextToGRPC(options.otTracer, options.logger)))...,
 		).Endpoint()
+		{{ToLowerFirstCamelCase $method.Name}}Endpoint = decodeGRPCErrorMiddleware({{ToLowerFirstCamelCase $method.Name}}Endpoint)
 		for _, middlewareCreator := range options.middlewareCreators {
 			{{ToLowerFirstCamelCase $method.Name}}Endpoint = middlewareCreator(method)({{ToLowerFirstCamelCase $method.Name}}Endpoint)
 		}
@@ -105,6 +114,56 @@ func NewGRPCClient(conn *grpc.ClientConn, opts ...ClientOption) {{$servicePackag
 	}
 }
 
+// encodeGRPCError converts the error returned by the service to a gRPC status,
+// the code is kept and the details are carried by an ErrorInfo.
+func encodeGRPCError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	e := {{$servicePackageName}}.AsError(err)
+	st := status.New(codes.Code(e.Code), e.Message)
+	if len(e.Details) > 0 {
+		detailed, detailErr := st.WithDetails(&errdetails.ErrorInfo{
+			Reason:   e.Code.String(),
+			Metadata: e.Details,
+		})
+		if detailErr == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+// decodeGRPCError converts a gRPC status back to the error of the service.
+func decodeGRPCError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	e := &{{$servicePackageName}}.Error{
+		Code:    {{$servicePackageName}}.Code(st.Code()),
+		Message: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			e.Details = info.Metadata
+		}
+	}
+	return e
+}
+
+// decodeGRPCErrorMiddleware decodes the errors returned by a gRPC client endpoint,
+// so callers can use errors.Is with the sentinel errors of the service.
+func decodeGRPCErrorMiddleware(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := next(ctx, request)
+		if err != nil {
+			return nil, decodeGRPCError(err)
+		}
+		return response, nil
+	}
+}
+
 {{range .RequestAndResponseList}}
 {{if .Request}}
 // decodeGRPC{{.Request.Name}} is a transport/grpc.DecodeRequestFunc that converts a
@@ -114,11 +173,18 @@ func decodeGRPC{{.Request.Name}}(_ context.Context, grpcReq interface{}) (interf
             return nil, nil
         }
 	req := grpcReq.(*{{$protobufPackageName}}.{{.Request.Name}})
-        {{if not .Request}}_ = req{{end}}
-	return &{{$servicePackageName}}.{{.Request.Name}}{
-            {{$alias := NewObjectAlias "req" $protobufPackageName .Request.Name true}}
+	{{if not .Request.Fields}}_ = req{{end}}
+	var err error
+	{{$alias := NewObjectAlias "req" $protobufPackageName .Request.Name true}}
+	v := &{{$servicePackageName}}.{{.Request.Name}}{
             {{GenerateAssignmentSegment .Request $pbRequestAndResponseList $alias}}
-        }, nil
+        }
+	if err != nil {
+		// 转换失败说明数据无法在两端之间表示 e.g. 整数溢出、未定义的枚举值
+		// 只有服务端收到的请求无法转换时才是调用方的参数错误
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return v, nil
 }
 {{end}}
 
@@ -130,10 +196,17 @@ func decodeGRPC{{.Response.Name}}(_ context.Context, grpcResponse interface{}) (
             return nil, nil
         }
 	resp := grpcResponse.(*{{$protobufPackageName}}.{{.Response.Name}})
-	return &{{$servicePackageName}}.{{.Response.Name}}{
-            {{$alias := NewObjectAlias "resp" $protobufPackageName .Response.Name true}}
+	{{if not .Response.Fields}}_ = resp{{end}}
+	var err error
+	{{$alias := NewObjectAlias "resp" $protobufPackageName .Response.Name true}}
+	v := &{{$servicePackageName}}.{{.Response.Name}}{
             {{GenerateAssignmentSegment .Response $pbRequestAndResponseList $alias}}
-        }, nil
+        }
+	if err != nil {
+		// 转换失败说明数据无法在两端之间表示 e.g. 整数溢出、未定义的枚举值
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return v, nil
 }
 {{end}}
 {{end}}
@@ -148,11 +221,17 @@ func encodeGRPC{{.Request.Name}}(_ context.Context, request interface{}) (interf
             return nil, nil
         }
 	req := request.(*{{$servicePackageName}}.{{.Request.Name}})
-        {{if not .Request}}_ = req{{end}}
-	return &{{$protobufPackageName}}.{{.Request.Name}}{
-            {{$alias := NewObjectAlias "req" $servicePackageName .Request.Name true}}
+	{{if not .Request.Fields}}_ = req{{end}}
+	var err error
+	{{$alias := NewObjectAlias "req" $servicePackageName .Request.Name true}}
+	v := &{{$protobufPackageName}}.{{.Request.Name}}{
             {{GenerateAssignmentSegment .Request $requestAndResponseList $alias}}
-        }, nil
+        }
+	if err != nil {
+		// 转换失败说明数据无法在两端之间表示 e.g. 整数溢出、未定义的枚举值
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return v, nil
 }
 {{end}}
 
@@ -164,10 +243,18 @@ func encodeGRPC{{.Response.Name}}(_ context.Context, response interface{}) (inte
             return nil, nil
         }
 	resp := response.(*{{$servicePackageName}}.{{.Response.Name}})
-	return &{{$protobufPackageName}}.{{.Response.Name}}{
-            {{$alias := NewObjectAlias "resp" $servicePackageName .Response.Name true}}
+	{{if not .Response.Fields}}_ = resp{{end}}
+	var err error
+	{{$alias := NewObjectAlias "resp" $servicePackageName .Response.Name true}}
+	v := &{{$protobufPackageName}}.{{.Response.Name}}{
             {{GenerateAssignmentSegment .Response $requestAndResponseList $alias}}
-        }, nil
+        }
+	if err != nil {
+		// 转换失败说明数据无法在两端之间表示 e.g. 整数溢出、未定义的枚举值
+		// 服务端的响应无法转换是服务内部的错误，不是调用方的参数错误
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return v, nil
 }
 {{end}}
 
@@ -182,13 +269,17 @@ package {{.PackageName}}
 import (
 	"bytes"
 	"context"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 
 	{{$servicePackageName}} "{{.ServiceImportPath}}"
@@ -207,16 +298,33 @@ import (
 func NewHTTPHandler(opts ...Option) http.Handler {
 	options := newOptions(opts...)
 
+	serverOptions := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(errorEncoder),
+	}
+	serverOptions = append(serverOptions, options.httpServerOptions...)
+
 	m := http.NewServeMux()
+{{- if .HasHTTPRoute}}
+	// routes declared by kit:http, the other methods are served on default paths
+	r := newRouter(m)
+{{- end}}
 {{range $index, $method := .ServiceMethods}}
+{{- if $method.Directives.HasHTTP}}
+	r.Handle("{{$method.Directives.HTTPMethod}}", "{{$method.Directives.HTTPPath}}", httptransport.NewServer(
+{{- else}}
 	m.Handle("/{{ToLowerFirstCamelCase $method.Name}}", httptransport.NewServer(
+{{- end}}
 		options.endpoints.{{$method.Name}}Endpoint.Do,
 		decodeHTTP{{$method.Name}}Request,
 		encodeHTTPGenericResponse,
-		append(options.httpServerOptions, httptransport.ServerBefore(opentracing.HTTPToContext(options.otTracer, "{{$method.Name}}", options.logger)))...,
+		append(serverOptions, httptransport.ServerBefore(opentracing.HTTPToContext(options.otTracer, "{{$method.Name}}", options.logger)))...,
 	))
 {{end}}
+{{- if .HasHTTPRoute}}
+	return r
+{{- else}}
 	return m
+{{- end}}
 }
 
 // NewHTTPClient returns an AddService backed by an HTTP server living at the
@@ -240,9 +348,15 @@ func NewHTTPClient(instance string, opts ...ClientOption) ({{$servicePackageName
 	{
 		method := "{{$method.Name}}"
 		{{ToLowerFirstCamelCase $method.Name}}Endpoint := httptransport.NewClient(
+		{{- if $method.Directives.HasHTTP}}
+			"{{$method.Directives.HTTPMethod}}",
+			copyURL(u, "{{$method.Directives.HTTPPath}}"),
+			encodeHTTPRouteRequest("{{$method.Directives.HTTPPath}}", {{$method.Directives.HTTPBody}}),
+		{{- else}}
 			"POST",
 			copyURL(u, "/{{ToLowerFirstCamelCase $method.Name}}"),
 			encodeHTTPGenericRequest,
+		{{- end}}
 			decodeHTTP{{$method.Name}}Response,
 			append(options.httpClientOptions, httptransport.ClientBefore(opentracing.ContextToHTTP(options.otTracer, options.logger)))...,
 		).Endpoint()
@@ -268,58 +382,117 @@ func copyURL(base *url.URL, path string) *url.URL {
 	return &next
 }
 
+// errorEncoder writes the error as a JSON problem body(RFC 7807) with the
+// HTTP status mapped from the error code.
 func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
-	w.WriteHeader(err2code(err))
-	json.NewEncoder(w).Encode(errorWrapper{Error: err.Error()})
+	e := {{$servicePackageName}}.AsError(err)
+	statusCode := err2code(e)
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(problem{
+		Type:    "about:blank",
+		Title:   http.StatusText(statusCode),
+		Status:  statusCode,
+		Detail:  e.Message,
+		Code:    e.Code.String(),
+		Details: e.Details,
+	})
 }
 
 func err2code(err error) int {
-	//switch err {
-	//case addservice.ErrTwoZeroes, addservice.ErrMaxSizeExceeded, addservice.ErrIntOverflow:
-	//	return http.StatusBadRequest
-	//}
-	// TODO
-	if err != nil {
-		return http.StatusInternalServerError
+	switch {{$servicePackageName}}.ErrorCode(err) {
+	case {{$servicePackageName}}.CodeOK:
+		return http.StatusOK
+{{- range .ErrorCodes}}
+	case {{$servicePackageName}}.Code{{.Name}}:
+		return {{.HTTPStatus}}
+{{- end}}
 	}
-	return http.StatusOK
+	return http.StatusInternalServerError
 }
 
+// errorDecoder decodes the problem body back to the error of the service,
+// responses without problem body are treated as CodeUnknown.
 func errorDecoder(r *http.Response) error {
-	var w errorWrapper
-	if err := json.NewDecoder(r.Body).Decode(&w); err != nil {
-		return err
+	var p problem
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.Code == "" {
+		return &{{$servicePackageName}}.Error{Code: {{$servicePackageName}}.CodeUnknown, Message: r.Status}
 	}
-	return errors.New(w.Error)
+	code, ok := {{$servicePackageName}}.ParseCode(p.Code)
+	if !ok {
+		return &{{$servicePackageName}}.Error{Code: {{$servicePackageName}}.CodeUnknown, Message: p.Detail, Details: p.Details}
+	}
+	return &{{$servicePackageName}}.Error{Code: code, Message: p.Detail, Details: p.Details}
 }
 
-type errorWrapper struct {
-	Error string ` + "`json:\"error\"`" + `
+// problem is the JSON problem body of errors.
+type problem struct {
+	Type    string            ` + "`json:\"type\"`" + `
+	Title   string            ` + "`json:\"title\"`" + `
+	Status  int               ` + "`json:\"status\"`" + `
+	Detail  string            ` + "`json:\"detail,omitempty\"`" + `
+	Code    string            ` + "`json:\"code\"`" + `
+	Details map[string]string ` + "`json:\"details,omitempty\"`" + `
 }
 
 {{range .RequestAndResponseList}}
 {{if .Request}}
+{{- $directives := index $.Directives .MethodName}}
+{{- if $directives.HasHTTP}}
+// decodeHTTP{{.Request.Name}} is a transport/http.DecodeRequestFunc that decodes
+// {{.Request.Name}} from the route {{$directives.HTTPMethod}} {{$directives.HTTPPath}} declared by kit:http.
+{{- if $directives.HTTPBody}}
+// The request is JSON-encoded in the body, the path parameters override the
+// decoded fields. Primarily useful in a server.
+{{- else}}
+// The path and query parameters are bound to the fields of the request.
+// Primarily useful in a server.
+{{- end}}
+func decodeHTTP{{.Request.Name}}(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req {{$servicePackageName}}.{{.Request.Name}}
+{{- if $directives.HTTPBody}}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		return nil, {{$servicePackageName}}.NewInvalidArgument("%v", err)
+	}
+	params := pathParams(ctx)
+{{- else}}
+	params := pathParams(ctx)
+	query := r.URL.Query()
+	for name := range query {
+		if _, found := params[name]; !found {
+			params[name] = query.Get(name)
+		}
+	}
+{{- end}}
+	if err := bindParams(&req, params); err != nil {
+		return nil, {{$servicePackageName}}.NewInvalidArgument("%v", err)
+	}
+	return &req, nil
+}
+{{- else}}
 // decodeHTTP{{.Request.Name}} is a transport/http.DecodeRequestFunc that decodes a
 // JSON-encoded {{.Request.Name}} from the HTTP request body. Primarily useful in a
 // server.
 func decodeHTTP{{.Request.Name}}(_ context.Context, r *http.Request) (interface{}, error) {
-	var req addservice.{{.Request.Name}}
-	err := json.NewDecoder(r.Body).Decode(&req)
-	return &req, err
+	var req {{$servicePackageName}}.{{.Request.Name}}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, {{$servicePackageName}}.NewInvalidArgument("%v", err)
+	}
+	return &req, nil
 }
+{{- end}}
 {{end}}
 
 {{if .Response}}
 // decodeHTTP{{.Response.Name}} is a transport/http.DecodeResponseFunc that decodes a
 // JSON-encoded {{.Response.Name}} from the HTTP response body. If the response has a
-// non-200 status code, we will interpret that as an error and attempt to decode
-// the specific error message from the response body. Primarily useful in a
-// client.
+// non-200 status code, we will interpret that as an error and decode the
+// problem body to the error of the service. Primarily useful in a client.
 func decodeHTTP{{.Response.Name}}(_ context.Context, r *http.Response) (interface{}, error) {
 	if r.StatusCode != http.StatusOK {
-		return nil, errors.New(r.Status)
+		return nil, errorDecoder(r)
 	}
-	var resp addservice.{{.Response.Name}}
+	var resp {{$servicePackageName}}.{{.Response.Name}}
 	err := json.NewDecoder(r.Body).Decode(&resp)
 	return &resp, err
 }
@@ -337,6 +510,262 @@ func encodeHTTPGenericRequest(_ context.Context, r *http.Request, request interf
 	return nil
 }
 
+{{if .HasHTTPRoute}}
+// encodeHTTPRouteRequest returns a transport/http.EncodeRequestFunc that fills
+// the path parameters of pattern e.g. /users/{id} with the request fields. The
+// request is JSON-encoded to the body, or to the query parameters without body.
+// Primarily useful in a client.
+func encodeHTTPRouteRequest(pattern string, body bool) httptransport.EncodeRequestFunc {
+	return func(ctx context.Context, r *http.Request, request interface{}) error {
+		v := reflect.Indirect(reflect.ValueOf(request))
+		segments := strings.Split(pattern, "/")
+		inPath := map[int]bool{}
+		for i, segment := range segments {
+			name, ok := pathParamName(segment)
+			if !ok {
+				continue
+			}
+			index, found := paramField(v, name)
+			if !found {
+				return fmt.Errorf("path parameter %s has no matching field", name)
+			}
+			value, err := formatParam(v.Field(index))
+			if err != nil {
+				return err
+			}
+			segments[i] = url.PathEscape(value)
+			inPath[index] = true
+		}
+		r.URL.RawPath = strings.Join(segments, "/")
+		path, err := url.PathUnescape(r.URL.RawPath)
+		if err != nil {
+			return err
+		}
+		r.URL.Path = path
+
+		if body {
+			return encodeHTTPGenericRequest(ctx, r, request)
+		}
+
+		query := url.Values{}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" || inPath[i] || v.Field(i).IsZero() {
+				continue
+			}
+			value, err := formatParam(v.Field(i))
+			if err != nil {
+				return err
+			}
+			query.Set(paramName(field), value)
+		}
+		r.URL.RawQuery = query.Encode()
+		return nil
+	}
+}
+
+// router serves the methods declared by kit:http e.g. GET /users/{id}, the
+// path parameters are passed to the decoders by the context. Requests that
+// match no route are served by the fallback handler.
+type router struct {
+	routes   []route
+	fallback http.Handler
+}
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.Handler
+}
+
+type pathParamsKey struct{}
+
+func newRouter(fallback http.Handler) *router {
+	return &router{fallback: fallback}
+}
+
+func (rt *router) Handle(method, pattern string, handler http.Handler) {
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		segments: strings.Split(pattern, "/"),
+		handler:  handler,
+	})
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	for _, route := range rt.routes {
+		params, ok := route.match(r.URL.EscapedPath())
+		if !ok {
+			continue
+		}
+		if route.method != r.Method {
+			allowed = append(allowed, route.method)
+			continue
+		}
+		route.handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, params)))
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	rt.fallback.ServeHTTP(w, r)
+}
+
+func (rt route) match(path string) (map[string]string, bool) {
+	segments := strings.Split(path, "/")
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range rt.segments {
+		name, ok := pathParamName(segment)
+		if !ok {
+			if segment != segments[i] {
+				return nil, false
+			}
+			continue
+		}
+		value, err := url.PathUnescape(segments[i])
+		if err != nil || value == "" {
+			return nil, false
+		}
+		params[name] = value
+	}
+	return params, true
+}
+
+// pathParams returns a copy of the path parameters matched by the router.
+func pathParams(ctx context.Context) map[string]string {
+	params := map[string]string{}
+	matched, _ := ctx.Value(pathParamsKey{}).(map[string]string)
+	for name, value := range matched {
+		params[name] = value
+	}
+	return params
+}
+
+func pathParamName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// bindParams sets the request fields from the parameters, a parameter matches
+// the field name or the json name case-insensitively, unknown parameters are
+// ignored.
+func bindParams(request interface{}, params map[string]string) error {
+	v := reflect.ValueOf(request).Elem()
+	for name, value := range params {
+		index, found := paramField(v, name)
+		if !found {
+			continue
+		}
+		if err := setParam(v.Field(index), value); err != nil {
+			return fmt.Errorf("invalid parameter %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func paramField(v reflect.Value, name string) (int, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if strings.EqualFold(field.Name, name) || (jsonName != "" && strings.EqualFold(jsonName, name)) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func paramName(field reflect.StructField) string {
+	if jsonName := strings.Split(field.Tag.Get("json"), ",")[0]; jsonName != "" && jsonName != "-" {
+		return jsonName
+	}
+	return field.Name
+}
+
+// formatParam formats scalar values and encoding.TextMarshaler e.g. time.Time
+// as text, other values are JSON-encoded.
+func formatParam(v reflect.Value) (string, error) {
+	v = reflect.Indirect(v)
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	b, err := json.Marshal(v.Interface())
+	return string(b), err
+}
+
+// setParam is the reverse of formatParam.
+func setParam(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setParam(ptr.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return json.Unmarshal([]byte(value), v.Addr().Interface())
+	}
+	return nil
+}
+{{end}}
+
 // encodeHTTPGenericResponse is a transport/http.EncodeResponseFunc that encodes
 // the response as JSON to the response writer. Primarily useful in a server.
 func encodeHTTPGenericResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
@@ -480,3 +909,328 @@ func WithClientTransportOptions(opts ...grpctransport.ClientOption) ClientOption
 	}
 }
 `
+
+var DefaultThriftTemplate = `
+{{$servicePackageName := BasePath .ServiceImportPath}}
+{{$endpointPackageName := BasePath .EndpointImportPath}}
+{{$thriftPackageName := BasePath .ThriftImportPath}}
+{{$baseServiceName := .BaseServiceName}}
+{{$requestAndResponseList := .RequestAndResponseList}}
+{{$thriftRequestAndResponseList := .ThriftCST.RequestAndResponseList}}
+package {{.PackageName}}
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+
+	{{$servicePackageName}} "{{.ServiceImportPath}}"
+        {{$endpointPackageName}} "{{.EndpointImportPath}}"
+        {{$thriftPackageName}} "{{.ThriftImportPath}}"
+        "ezrpro.com/micro/spiderconn"
+        {{range .TypeConverterImports}}
+        "{{.}}"
+        {{- end}}
+)
+
+type thriftServer struct {
+{{range $index, $method := .ServiceMethods}}
+	{{ToLowerFirstCamelCase $method.Name}} *thriftHandler
+{{end}}
+}
+
+// NewThriftServer makes a set of endpoints available as a Thrift {{ToCamelCase $baseServiceName}} service.
+func NewThriftServer(opts ...Option) {{$thriftPackageName}}.{{ToCamelCase $baseServiceName}} {
+	options := newOptions(opts...)
+
+	return &thriftServer{
+{{range $index, $method := .ServiceMethods}}
+		{{ToLowerFirstCamelCase $method.Name}}: newThriftHandler(
+			options.endpoints.{{$method.Name}}Endpoint.Do,
+			decodeThrift{{$method.Name}}Request,
+			encodeThrift{{$method.Name}}Response,
+			options.logger,
+		),
+{{end}}
+	}
+}
+{{range $index, $method := .ServiceMethods}}
+func (s *thriftServer) {{$method.Name}}(ctx context.Context, req *{{$thriftPackageName}}.{{$method.Name}}Request) (*{{$thriftPackageName}}.{{$method.Name}}Response, error) {
+	resp, err := s.{{ToLowerFirstCamelCase $method.Name}}.ServeThrift(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*{{$thriftPackageName}}.{{$method.Name}}Response), nil
+}
+{{end}}
+
+// NewThriftClient returns an {{.ServiceName}} backed by a Thrift server at the other end
+// of the client. The caller is responsible for constructing the client, and
+// eventually closing the underlying transport. We bake-in certain middlewares,
+// implementing the client library pattern.
+func NewThriftClient(client {{$thriftPackageName}}.{{ToCamelCase $baseServiceName}}, opts ...ClientOption) {{$servicePackageName}}.{{.ServiceName}} {
+	options := newClientOption(opts...)
+
+{{range $index, $method := .ServiceMethods}}
+	var {{ToLowerFirstCamelCase $method.Name}}Wrapper spiderconn.EndpointWrapper
+	{
+		method := "{{$method.Name}}"
+		{{ToLowerFirstCamelCase $method.Name}}Endpoint := newThriftClientEndpoint(
+			func(ctx context.Context, request interface{}) (interface{}, error) {
+				return client.{{$method.Name}}(ctx, request.(*{{$thriftPackageName}}.{{$method.Name}}Request))
+			},
+			encodeThrift{{$method.Name}}Request,
+			decodeThrift{{$method.Name}}Response,
+		)
+		for _, middlewareCreator := range options.middlewareCreators {
+			{{ToLowerFirstCamelCase $method.Name}}Endpoint = middlewareCreator(method)({{ToLowerFirstCamelCase $method.Name}}Endpoint)
+		}
+		{{ToLowerFirstCamelCase $method.Name}}Wrapper = spiderconn.NewWrapper(method, {{ToLowerFirstCamelCase $method.Name}}Endpoint)
+	}
+{{end}}
+	// Returning the endpoint.Set as a {{$servicePackageName}}.{{.ServiceName}} relies on the
+	// endpoint.Set implementing the {{.ServiceName}} methods. That's just a simple bit
+	// of glue code.
+	return {{$endpointPackageName}}.Set{
+{{range $index, $method := .ServiceMethods}}
+		{{$method.Name}}Endpoint: {{ToLowerFirstCamelCase $method.Name}}Wrapper,
+{{end}}
+	}
+}
+
+// thriftCodecFunc 在thrift结构体和user-domain结构体之间转换
+type thriftCodecFunc func(context.Context, interface{}) (interface{}, error)
+
+// thriftHandler 参照go-kit transport/grpc.Server，将endpoint包装为thrift的服务方法
+type thriftHandler struct {
+	e      endpoint.Endpoint
+	dec    thriftCodecFunc
+	enc    thriftCodecFunc
+	logger log.Logger
+}
+
+func newThriftHandler(e endpoint.Endpoint, dec, enc thriftCodecFunc, logger log.Logger) *thriftHandler {
+	return &thriftHandler{
+		e:      e,
+		dec:    dec,
+		enc:    enc,
+		logger: logger,
+	}
+}
+
+func (h *thriftHandler) ServeThrift(ctx context.Context, req interface{}) (interface{}, error) {
+	request, err := h.dec(ctx, req)
+	if err != nil {
+		h.logger.Log("err", err)
+		return nil, err
+	}
+
+	response, err := h.e(ctx, request)
+	if err != nil {
+		h.logger.Log("err", err)
+		return nil, err
+	}
+
+	resp, err := h.enc(ctx, response)
+	if err != nil {
+		h.logger.Log("err", err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// newThriftClientEndpoint 参照go-kit transport/grpc.Client，将thrift的客户端方法包装为endpoint
+func newThriftClientEndpoint(call endpoint.Endpoint, enc, dec thriftCodecFunc) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, err := enc(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		reply, err := call(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		return dec(ctx, reply)
+	}
+}
+
+{{range .RequestAndResponseList}}
+{{if .Request}}
+// decodeThrift{{.Request.Name}} converts a Thrift {{.Request.Name}} to a
+// user-domain {{.Request.Name}}. Primarily useful in a server.
+func decodeThrift{{.Request.Name}}(_ context.Context, thriftReq interface{}) (interface{}, error) {
+        if thriftReq == nil {
+            return nil, nil
+        }
+	req := thriftReq.(*{{$thriftPackageName}}.{{.Request.Name}})
+	{{if not .Request.Fields}}_ = req{{end}}
+	var err error
+	{{$alias := NewThriftObjectAlias "req" $thriftPackageName .Request.Name true}}
+	v := &{{$servicePackageName}}.{{.Request.Name}}{
+            {{GenerateThriftAssignmentSegment .Request $thriftRequestAndResponseList $alias}}
+        }
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+{{end}}
+
+{{if .Response}}
+// decodeThrift{{.Response.Name}} converts a Thrift {{.Response.Name}} to a
+// user-domain {{.Response.Name}}. Primarily useful in a client.
+func decodeThrift{{.Response.Name}}(_ context.Context, thriftResponse interface{}) (interface{}, error) {
+        if thriftResponse == nil {
+            return nil, nil
+        }
+	resp := thriftResponse.(*{{$thriftPackageName}}.{{.Response.Name}})
+	{{if not .Response.Fields}}_ = resp{{end}}
+	var err error
+	{{$alias := NewThriftObjectAlias "resp" $thriftPackageName .Response.Name true}}
+	v := &{{$servicePackageName}}.{{.Response.Name}}{
+            {{GenerateThriftAssignmentSegment .Response $thriftRequestAndResponseList $alias}}
+        }
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+{{end}}
+{{end}}
+
+
+{{range .ThriftCST.RequestAndResponseList}}
+{{if .Request}}
+// encodeThrift{{.Request.Name}} converts a user-domain {{.Request.Name}} to a
+// Thrift {{.Request.Name}}. Primarily useful in a client.
+func encodeThrift{{.Request.Name}}(_ context.Context, request interface{}) (interface{}, error) {
+        if request == nil {
+            return nil, nil
+        }
+	req := request.(*{{$servicePackageName}}.{{.Request.Name}})
+	{{if not .Request.Fields}}_ = req{{end}}
+	var err error
+	{{$alias := NewThriftObjectAlias "req" $servicePackageName .Request.Name true}}
+	v := &{{$thriftPackageName}}.{{.Request.Name}}{
+            {{GenerateThriftAssignmentSegment .Request $requestAndResponseList $alias}}
+        }
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+{{end}}
+
+{{if .Response}}
+// encodeThrift{{.Response.Name}} converts a user-domain {{.Response.Name}} to a
+// Thrift {{.Response.Name}}. Primarily useful in a server.
+func encodeThrift{{.Response.Name}}(_ context.Context, response interface{}) (interface{}, error) {
+        if response == nil {
+            return nil, nil
+        }
+	resp := response.(*{{$servicePackageName}}.{{.Response.Name}})
+	{{if not .Response.Fields}}_ = resp{{end}}
+	var err error
+	{{$alias := NewThriftObjectAlias "resp" $servicePackageName .Response.Name true}}
+	v := &{{$thriftPackageName}}.{{.Response.Name}}{
+            {{GenerateThriftAssignmentSegment .Response $requestAndResponseList $alias}}
+        }
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+{{end}}
+
+{{end}}
+`
+
+var DefaultConvertTemplate = `
+{{$servicePackageName := BasePath .ServiceImportPath}}
+{{$protobufPackageName := BasePath .ProtobufImportPath}}
+{{$thriftPackageName := BasePath .ThriftImportPath}}
+package {{.PackageName}}
+
+import (
+	"github.com/golang/protobuf/proto"
+
+	{{$servicePackageName}} "{{.ServiceImportPath}}"
+        {{$protobufPackageName}} "{{.ProtobufImportPath}}"
+        {{$thriftPackageName}} "{{.ThriftImportPath}}"
+        {{range .TypeConverterImports}}
+        "{{.}}"
+        {{- end}}
+)
+
+{{.Converters}}
+`
+
+var DefaultConvertTestTemplate = `
+{{$servicePackageName := BasePath .ServiceImportPath}}
+package {{.PackageName}}
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	{{$servicePackageName}} "{{.ServiceImportPath}}"
+        {{range .TypeConverterImports}}
+        "{{.}}"
+        {{- end}}
+)
+
+{{range .RoundTrips}}
+{{$name := .Name}}
+// roundTripGRPC{{$name}} converts a user-domain {{$name}} to gRPC and back,
+// and asserts that nothing is lost on the way.
+func roundTripGRPC{{$name}}(t *testing.T, in *{{$servicePackageName}}.{{$name}}) {
+	t.Helper()
+
+	grpcMsg, err := encodeGRPC{{$name}}(context.Background(), in)
+	if err != nil {
+		t.Fatalf("encode {{$name}}: %v", err)
+	}
+
+	out, err := decodeGRPC{{$name}}(context.Background(), grpcMsg)
+	if err != nil {
+		t.Fatalf("decode {{$name}}: %v", err)
+	}
+
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("{{$name}} round trip mismatch\n in: %+v\nout: %+v", in, out)
+	}
+}
+
+func TestGRPC{{$name}}RoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		in   *{{$servicePackageName}}.{{$name}}
+	}{
+		{name: "zero", in: &{{$servicePackageName}}.{{$name}}{}},
+		{name: "populated", in: {{.Sample}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			roundTripGRPC{{$name}}(t, c.in)
+		})
+	}
+}
+
+{{if .FuzzParams}}
+func FuzzGRPC{{$name}}(f *testing.F) {
+	f.Add({{range $i, $p := .FuzzParams}}{{if $i}}, {{end}}{{$p.Seed}}{{end}})
+	f.Fuzz(func(t *testing.T, {{range $i, $p := .FuzzParams}}{{if $i}}, {{end}}{{$p.Var}} {{$p.Type}}{{end}}) {
+		in := {{.Sample}}{{range .FuzzParams}}
+		in.{{.Field}} = {{if .Star}}&{{end}}{{.Var}}{{end}}
+
+		roundTripGRPC{{$name}}(t, in)
+	})
+}
+{{end}}
+{{end}}
+`
@@ -1,14 +1,19 @@
 package transport
 
 import (
+	"bytes"
+	"errors"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
 
 	"ezrpro.com/micro/kit/pkg/cst"
 	gen "ezrpro.com/micro/kit/pkg/generator"
 	"ezrpro.com/micro/kit/pkg/generator/assignment"
 	"ezrpro.com/micro/kit/pkg/utils"
+	"github.com/sirupsen/logrus"
 )
 
 type TransportGenerator struct {
@@ -26,35 +31,103 @@ func NewTransportGenerator(t cst.ConcreteSyntaxTree, opts ...Option) gen.Generat
 }
 
 func (g *TransportGenerator) Generate() error {
-	for tplName, readWriter := range g.opts.readWriterMap {
-		tplBody, err := ioutil.ReadAll(readWriter.template)
-		if err != nil {
-			return err
-		}
+	// 非标准签名的方法使用合成的请求及响应结构体
+	tree, err := gen.NewEnvelopeTree(g.cst, g.opts.serviceSuffix)
+	if err != nil {
+		return err
+	}
+	g.cst = tree
+
+	// kit:http声明的路径参数必须对应请求结构体中的字段
+	serviceIface, err := gen.FilterInterface(g.cst.Interfaces(), g.opts.serviceSuffix)
+	if err != nil {
+		return err
+	}
+	if err := gen.CheckHTTPRoutes(g.cst, serviceIface); err != nil {
+		return err
+	}
+	directives := map[string]cst.Directives{}
+	for _, method := range serviceIface.Methods {
+		directives[method.Name] = method.Directives
+	}
+
+	pbCST, err := getProtobufCST(
+		g.opts.pbGoPath,
+		g.opts.baseServiceName,
+		g.cst.PackageName(),
+	)
+	if err != nil {
+		return err
+	}
 
-		pbCST, err := getProtobufCST(
-			g.opts.pbGoPath,
-			g.opts.baseServiceName,
-			g.cst.PackageName(),
+	// 各个transport共用factory，生成的转换方法统一输出到convert.go
+	var (
+		pbFactory = assignment.NewGeneratorFactory(
+			g.cst,
+			pbCST,
+			assignment.WithMatchStrategy(g.opts.matchStrategy),
+			assignment.WithEnumUnknown(g.opts.enumUnknown),
+			assignment.WithTypeConverters(g.opts.typeConverters),
+			assignment.WithUncheckedNarrowing(g.opts.uncheckedNarrowing),
 		)
+		thriftFactory *assignment.GeneratorFactory
+		// 严格模式下存在未赋值的字段时不能输出任何文件，先缓存生成的代码
+		outputs = map[Template]*bytes.Buffer{}
+	)
+
+	for _, tplName := range g.sortedTemplates() {
+		readWriter := g.opts.readWriterMap[tplName]
+		tplBody, err := ioutil.ReadAll(readWriter.template)
 		if err != nil {
 			return err
 		}
 
-		t := template.New(string(tplName)).Funcs(map[string]interface{}{
+		funcs := map[string]interface{}{
 			"ToLowerFirstCamelCase":     utils.ToLowerFirstCamelCase,
 			"ToCamelCase":               utils.ToCamelCase,
 			"BasePath":                  filepath.Base,
-			"GenerateAssignmentSegment": assignment.NewGeneratorFactory(g.cst, pbCST).Generate,
+			"GenerateAssignmentSegment": pbFactory.Generate,
 			"NewSimpleAlias":            assignment.NewSimpleAlias,
 			"NewObjectAlias":            assignment.NewObjectAlias(g.cst, pbCST),
-		})
-		t, err = t.Parse(string(tplBody))
-		if err != nil {
-			return err
 		}
 
-		serviceIface, err := gen.FilterInterface(g.cst.Interfaces(), g.opts.serviceSuffix)
+		var roundTrips []roundTrip
+		// 往返测试依赖grpc.go中的decode/encode方法
+		if tplName == ConvertTestTemplate {
+			roundTrips = newSampleGenerator(g.cst, pbCST, pbFactory, g.opts.typeConverters).roundTrips()
+		}
+
+		var thriftCSTData map[string]interface{}
+		// thrift生成的go代码仅在生成thrift transport时需要
+		if tplName == ThriftTemplate {
+			thriftCST, err := getThriftCST(
+				g.opts.thriftGoPath,
+				g.opts.baseServiceName,
+				g.cst.PackageName(),
+			)
+			if err != nil {
+				return err
+			}
+			thriftFactory = assignment.NewGeneratorFactory(
+				g.cst,
+				thriftCST,
+				assignment.WithConverterPrefix("thrift"),
+				assignment.WithSchema(assignment.SchemaThrift),
+				assignment.WithMatchStrategy(g.opts.matchStrategy),
+				assignment.WithEnumUnknown(g.opts.enumUnknown),
+				assignment.WithTypeConverters(g.opts.typeConverters),
+				assignment.WithUncheckedNarrowing(g.opts.uncheckedNarrowing),
+			)
+			funcs["GenerateThriftAssignmentSegment"] = thriftFactory.Generate
+			funcs["NewThriftObjectAlias"] = assignment.NewObjectAlias(g.cst, thriftCST)
+			thriftCSTData = map[string]interface{}{
+				"PackageName":            thriftCST.PackageName(),
+				"RequestAndResponseList": gen.GetRequestAndResponseList(thriftCST),
+			}
+		}
+
+		t := template.New(string(tplName)).Funcs(funcs)
+		t, err = t.Parse(string(tplBody))
 		if err != nil {
 			return err
 		}
@@ -65,7 +138,9 @@ func (g *TransportGenerator) Generate() error {
 			return err
 		}
 
-		err = t.Execute(readWriter.writer, map[string]interface{}{
+		output := &bytes.Buffer{}
+		outputs[tplName] = output
+		err = t.Execute(output, map[string]interface{}{
 			"BaseServiceName":        g.opts.baseServiceName,
 			"PackageName":            g.opts.transportPackageName,
 			"ServiceName":            serviceIface.Name,
@@ -73,20 +148,84 @@ func (g *TransportGenerator) Generate() error {
 			"ServiceImportPath":      utils.GetServiceImportPath(g.opts.baseServiceName),
 			"EndpointImportPath":     utils.GetEndpointImportPath(g.opts.baseServiceName),
 			"ProtobufImportPath":     utils.GetProtobufImportPath(g.opts.baseServiceName),
+			"ThriftImportPath":       utils.GetThriftImportPath(g.opts.baseServiceName),
 			"RequestAndResponseList": gen.GetRequestAndResponseList(g.cst),
 			"ProtobufCST": map[string]interface{}{
 				"PackageName":            pbCST.PackageName(),
 				"ServiceName":            pbServiceIface.Name,
 				"RequestAndResponseList": gen.GetRequestAndResponseList(pbCST),
 			},
+			"ThriftCST":  thriftCSTData,
+			"Converters": converters(pbFactory, thriftFactory),
+			"RoundTrips": roundTrips,
+			// 服务错误码与gRPC及HTTP状态码的对应关系
+			"ErrorCodes": gen.ErrorCodes,
+			// 方法注释中的指令，key: 方法名
+			"Directives":   directives,
+			"HasHTTPRoute": gen.HasHTTPRoute(serviceIface.Methods),
+			// 自定义类型转换方法所在的包，未使用的import由goimports移除
+			"TypeConverterImports": g.opts.typeConverters.Imports(),
 		})
 		if err != nil {
 			return err
 		}
 	}
+
+	// 目标字段没有被赋值或者枚举值没有对应的成员，意味着转换时会丢失数据
+	if fields := unmappedFields(pbFactory, thriftFactory); len(fields) > 0 {
+		msg := "The following fields or enum members have no mapping:\n\t" + strings.Join(fields, "\n\t")
+		if g.opts.strict {
+			return errors.New(msg)
+		}
+		logrus.Warn(msg)
+	}
+
+	for _, tplName := range g.sortedTemplates() {
+		if _, err := outputs[tplName].WriteTo(g.opts.readWriterMap[tplName].writer); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// convert.go中的转换方法是在生成其他transport时收集的，需要最后生成
+func (g *TransportGenerator) sortedTemplates() []Template {
+	var templates []Template
+	for tplName := range g.opts.readWriterMap {
+		templates = append(templates, tplName)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i] == ConvertTemplate || templates[j] == ConvertTemplate {
+			return templates[j] == ConvertTemplate
+		}
+		return templates[i] < templates[j]
+	})
+	return templates
+}
+
+func converters(factories ...*assignment.GeneratorFactory) string {
+	bodies := map[string]string{}
+	for _, factory := range factories {
+		if factory == nil {
+			continue
+		}
+		for name, body := range factory.ConverterBodies() {
+			bodies[name] = body
+		}
+	}
+	return assignment.JoinConverters(bodies)
+}
+
+func unmappedFields(factories ...*assignment.GeneratorFactory) []string {
+	var fields []string
+	for _, factory := range factories {
+		if factory != nil {
+			fields = append(fields, factory.UnmappedFields()...)
+		}
+	}
+	return fields
+}
+
 func getProtobufCST(pbGoFilePath, baseServiceName, servicePackageName string) (cst.ConcreteSyntaxTree, error) {
 	if pbGoFilePath == "" {
 		pbGoPath := utils.GetProtobufFilePath(baseServiceName)
@@ -99,3 +238,17 @@ func getProtobufCST(pbGoFilePath, baseServiceName, servicePackageName string) (c
 	}
 	return pbCST, nil
 }
+
+// thrift --gen go 生成的代码文件名和idl文件名一致
+func getThriftCST(thriftGoFilePath, baseServiceName, servicePackageName string) (cst.ConcreteSyntaxTree, error) {
+	if thriftGoFilePath == "" {
+		thriftGoPath := utils.GetThriftFilePath(baseServiceName)
+		thriftGoFilePath = filepath.Join(thriftGoPath, servicePackageName+".go")
+	}
+
+	thriftCST, err := cst.New(thriftGoFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return thriftCST, nil
+}
@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+)
+
+// 自定义类型与proto类型之间的转换，在配置文件kit.yaml的gk_type_converters中声明
+// e.g. {go_type: decimal.Decimal, proto_type: string, to_proto: convert.DecimalToString,
+// from_proto: convert.StringToDecimal, from_proto_error: true, sample: decimal.NewFromInt(1),
+// imports: [github.com/shopspring/decimal, ezrpro.com/micro/demo/pkg/convert]}
+// to_proto和from_proto的参数及返回值均使用非指针的go类型
+// proto类型为message时，pb.go一端使用指针 e.g. func(time.Time) *timestamppb.Timestamp
+// 字段为指针时由生成的代码处理nil
+type TypeConverter struct {
+	GoType         string   `mapstructure:"go_type"`          // 带包名的go类型 e.g. decimal.Decimal
+	ProtoType      string   `mapstructure:"proto_type"`       // proto中的类型 e.g. string, google.protobuf.Timestamp
+	ProtoImport    string   `mapstructure:"proto_import"`     // proto_type需要import的文件 e.g. google/protobuf/timestamp.proto
+	ToProto        string   `mapstructure:"to_proto"`         // go类型转换为pb.go类型的方法
+	FromProto      string   `mapstructure:"from_proto"`       // pb.go类型转换为go类型的方法
+	ToProtoError   bool     `mapstructure:"to_proto_error"`   // to_proto是否返回(T, error)
+	FromProtoError bool     `mapstructure:"from_proto_error"` // from_proto是否返回(T, error)
+	Imports        []string `mapstructure:"imports"`          // 生成的代码中需要引入的包
+	Sample         string   `mapstructure:"sample"`           // convert_test.go中使用的非零样例值 e.g. decimal.NewFromInt(1)，为空时字段保持零值
+}
+
+// proto类型为标量时pb.go中是值类型，message则是指针
+func (c TypeConverter) IsScalarProtoType() bool {
+	switch c.ProtoType {
+	case "double", "float", "int32", "int64", "uint32", "uint64",
+		"sint32", "sint64", "fixed32", "fixed64", "sfixed32", "sfixed64",
+		"bool", "string", "bytes":
+		return true
+	}
+	return false
+}
+
+type TypeConverters []TypeConverter
+
+// 内置的time.Time和time.Duration与protobuf well-known types之间的转换
+// 转换方法由assignment生成器输出到convert.go中
+var BuiltinTypeConverters = TypeConverters{
+	{
+		GoType:      "time.Time",
+		ProtoType:   "google.protobuf.Timestamp",
+		ProtoImport: "google/protobuf/timestamp.proto",
+		ToProto:     "timeToPbTimestamp",
+		FromProto:   "pbTimestampToTime",
+		Imports:     []string{"time", "google.golang.org/protobuf/types/known/timestamppb"},
+		// Timestamp转换回来的时间为UTC
+		Sample: "time.Date(2006, 1, 2, 15, 4, 5, 999, time.UTC)",
+	},
+	{
+		GoType:      "time.Duration",
+		ProtoType:   "google.protobuf.Duration",
+		ProtoImport: "google/protobuf/duration.proto",
+		ToProto:     "durationToPbDuration",
+		FromProto:   "pbDurationToDuration",
+		Imports:     []string{"time", "google.golang.org/protobuf/types/known/durationpb"},
+		Sample:      "1500 * time.Millisecond",
+	},
+}
+
+// 追加内置的转换，配置中声明的同名类型优先
+func (cs TypeConverters) WithBuiltin() TypeConverters {
+	converters := append(TypeConverters{}, cs...)
+	for _, c := range BuiltinTypeConverters {
+		if !converters.contains(c.GoType) {
+			converters = append(converters, c)
+		}
+	}
+	return converters
+}
+
+func (cs TypeConverters) contains(goType string) bool {
+	for _, c := range cs {
+		if c.GoType == goType {
+			return true
+		}
+	}
+	return false
+}
+
+// 按带包名的类型名查找，忽略指针 e.g. *decimal.Decimal => decimal.Decimal
+func (cs TypeConverters) Find(t cst.BaseType) (TypeConverter, bool) {
+	if t.GoType != cst.StructType || t.X == "" {
+		return TypeConverter{}, false
+	}
+	for _, c := range cs {
+		if c.GoType == t.X+"."+t.Name {
+			return c, true
+		}
+	}
+	return TypeConverter{}, false
+}
+
+// 所有转换方法需要引入的包，去重并保持配置的顺序
+func (cs TypeConverters) Imports() []string {
+	var (
+		imports []string
+		exists  = map[string]struct{}{}
+	)
+	for _, c := range cs {
+		for _, imp := range c.Imports {
+			if _, found := exists[imp]; found {
+				continue
+			}
+			exists[imp] = struct{}{}
+			imports = append(imports, imp)
+		}
+	}
+	return imports
+}
+
+func (cs TypeConverters) Validate() error {
+	for _, c := range cs {
+		if !strings.Contains(c.GoType, ".") {
+			return fmt.Errorf("go_type(%s) of type converter must be qualified with package name, e.g. decimal.Decimal", c.GoType)
+		}
+		if c.ProtoType == "" || c.ToProto == "" || c.FromProto == "" {
+			return fmt.Errorf("proto_type, to_proto and from_proto of type converter(%s) are required", c.GoType)
+		}
+	}
+	return nil
+}
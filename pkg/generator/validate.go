@@ -0,0 +1,323 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+)
+
+// 请求结构体字段上声明校验规则的tag
+// e.g. `validate:"required,min=1,max=100,email,oneof=a b"`
+// omitempty: 字段为零值时跳过其他规则
+const validateTagKey = "validate"
+
+// 生成Validate方法的请求结构体
+type Validation struct {
+	Name   string   // 结构体名称
+	Checks []string // 各个字段的校验代码，校验失败时返回InvalidArgument错误
+}
+
+// 校验规则按字段类型生成不同的代码
+type validateKind int
+
+const (
+	validateUnsupported validateKind = iota
+	validateString
+	validateInt
+	validateUint
+	validateFloat
+	validateBool
+	validateLength // slice, map按长度校验
+	validateTime
+)
+
+// 数值类型及其位数，int和uint按64位处理
+var numberTypes = map[string]struct {
+	kind validateKind
+	bits int
+}{
+	"int": {validateInt, 64}, "int8": {validateInt, 8}, "int16": {validateInt, 16}, "int32": {validateInt, 32}, "int64": {validateInt, 64},
+	"uint": {validateUint, 64}, "uint8": {validateUint, 8}, "uint16": {validateUint, 16}, "uint32": {validateUint, 32}, "uint64": {validateUint, 64},
+	"float32": {validateFloat, 32}, "float64": {validateFloat, 64}, "byte": {validateUint, 8}, "rune": {validateInt, 32}, "uintptr": {validateUint, 64},
+}
+
+// 为带有validate tag的请求结构体生成校验代码，没有任何校验规则的结构体不生成Validate方法
+func NewValidations(tree cst.ConcreteSyntaxTree) ([]Validation, error) {
+	var validations []Validation
+	for _, rar := range GetRequestAndResponseList(tree) {
+		if rar.Request == nil {
+			continue
+		}
+
+		validation := Validation{Name: rar.Request.Name}
+		for _, field := range rar.Request.Fields {
+			tag := reflect.StructTag(field.Tag).Get(validateTagKey)
+			if tag == "" || tag == "-" {
+				continue
+			}
+
+			check, err := newFieldCheck(tree, field, tag)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", rar.Request.Name, field.Name, err)
+			}
+			validation.Checks = append(validation.Checks, check)
+		}
+
+		if len(validation.Checks) > 0 {
+			validations = append(validations, validation)
+		}
+	}
+	return validations, nil
+}
+
+// 是否有字段使用了email规则，需要生成校验email的方法
+func HasEmailValidation(validations []Validation) bool {
+	for _, validation := range validations {
+		for _, check := range validation.Checks {
+			if strings.Contains(check, "validateEmail(") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newFieldCheck(tree cst.ConcreteSyntaxTree, field cst.Field, tag string) (string, error) {
+	var (
+		kind      = getValidateKind(tree, field.Type)
+		bits      = numberTypes[underlyingType(tree, field.Type).Name].bits
+		ref       = "r." + field.Name
+		value     = ref
+		omitempty bool
+		required  string
+		checks    []string
+	)
+	// 指针字段非nil时才校验指向的值
+	if field.Type.Star && kind != validateLength {
+		value = "*" + ref
+	}
+	// 自定义字符串类型需要转换为string e.g. type Email string
+	str := value
+	if kind == validateString && field.Type.Name != "string" {
+		str = "string(" + value + ")"
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "omitempty":
+			omitempty = true
+		case "required":
+			zero, err := zeroCondition(kind, ref, value, field.Type.Star)
+			if err != nil {
+				return "", err
+			}
+			required = invalidArgument(zero, fmt.Sprintf("%s is required", field.Name), field.Name)
+		case "min", "max":
+			cond, msg, err := boundCondition(kind, bits, name, param, str)
+			if err != nil {
+				return "", err
+			}
+			checks = append(checks, invalidArgument(cond, field.Name+" "+msg, field.Name))
+		case "email":
+			if kind != validateString {
+				return "", fmt.Errorf("email rule requires a string field")
+			}
+			checks = append(checks, invalidArgument(
+				fmt.Sprintf("!validateEmail(%s)", str),
+				fmt.Sprintf("%s must be a valid email address", field.Name),
+				field.Name,
+			))
+		case "oneof":
+			cond, err := oneofCondition(kind, bits, param, value)
+			if err != nil {
+				return "", err
+			}
+			checks = append(checks, invalidArgument(
+				cond,
+				fmt.Sprintf("%s must be one of [%s]", field.Name, param),
+				field.Name,
+			))
+		default:
+			return "", fmt.Errorf("unsupported validate rule %q", rule)
+		}
+	}
+
+	body := strings.Join(checks, "\n")
+	if body != "" && omitempty {
+		zero, err := zeroCondition(kind, ref, value, field.Type.Star)
+		if err != nil {
+			return "", err
+		}
+		body = fmt.Sprintf("if %s {\n%s\n}", negateCondition(zero), body)
+	} else if body != "" && required == "" && field.Type.Star && kind != validateLength {
+		// required已经保证指针非nil
+		body = fmt.Sprintf("if %s != nil {\n%s\n}", ref, body)
+	}
+
+	if required != "" {
+		body = strings.TrimSuffix(required+"\n"+body, "\n")
+	}
+	return body, nil
+}
+
+func getValidateKind(tree cst.ConcreteSyntaxTree, typ cst.Type) validateKind {
+	if typ.X == "time" && typ.Name == "Time" {
+		return validateTime
+	}
+
+	typ = underlyingType(tree, typ)
+	switch typ.GoType {
+	case cst.ArrayType, cst.MapType:
+		return validateLength
+	case cst.BasicType:
+		switch {
+		case typ.Name == "string":
+			return validateString
+		case typ.Name == "bool":
+			return validateBool
+		case numberTypes[typ.Name].kind != validateUnsupported:
+			return numberTypes[typ.Name].kind
+		}
+	}
+	return validateUnsupported
+}
+
+// 自定义类型按其底层类型校验 e.g. type Status string => string
+func underlyingType(tree cst.ConcreteSyntaxTree, typ cst.Type) cst.Type {
+	if typ.GoType != cst.StructType || typ.X != "" {
+		return typ
+	}
+	strc, found := tree.StructMap()[tree.PackageName()][typ.Name]
+	if !found || strc.Type == nil {
+		return typ
+	}
+	underlying := *strc.Type
+	underlying.Star = false
+	return underlyingType(tree, underlying)
+}
+
+// 字段为零值的条件
+func zeroCondition(kind validateKind, ref, value string, star bool) (string, error) {
+	if star && kind != validateLength {
+		return ref + " == nil", nil
+	}
+
+	switch kind {
+	case validateString:
+		return value + ` == ""`, nil
+	case validateInt, validateUint, validateFloat:
+		return value + " == 0", nil
+	case validateBool:
+		return "!" + value, nil
+	case validateLength:
+		return "len(" + value + ") == 0", nil
+	case validateTime:
+		return value + ".IsZero()", nil
+	}
+	return "", fmt.Errorf("required rule is unsupported for the type of field")
+}
+
+// 对zeroCondition生成的条件取反
+func negateCondition(cond string) string {
+	switch {
+	case strings.HasPrefix(cond, "!"):
+		return cond[1:]
+	case strings.Contains(cond, " == "):
+		return strings.Replace(cond, " == ", " != ", 1)
+	}
+	return "!" + cond
+}
+
+// min, max对字符串校验字符数，对slice和map校验长度，对数值校验取值
+func boundCondition(kind validateKind, bits int, name, param, value string) (cond, msg string, err error) {
+	op, word := "<", "at least"
+	if name == "max" {
+		op, word = ">", "at most"
+	}
+
+	switch kind {
+	case validateString, validateLength:
+		if _, err := strconv.Atoi(param); err != nil {
+			return "", "", fmt.Errorf("%s rule requires an integer, got %q", name, param)
+		}
+		if kind == validateString {
+			return fmt.Sprintf("utf8.RuneCountInString(%s) %s %s", value, op, param),
+				fmt.Sprintf("must be %s %s characters", word, param), nil
+		}
+		return fmt.Sprintf("len(%s) %s %s", value, op, param),
+			fmt.Sprintf("must contain %s %s items", word, param), nil
+	case validateInt, validateUint, validateFloat:
+		if err := checkNumber(kind, bits, param); err != nil {
+			return "", "", fmt.Errorf("%s rule %v", name, err)
+		}
+		return fmt.Sprintf("%s %s %s", value, op, param),
+			fmt.Sprintf("must be %s %s", word, param), nil
+	}
+	return "", "", fmt.Errorf("%s rule is unsupported for the type of field", name)
+}
+
+// oneof的候选值以空格分隔 e.g. oneof=red green
+func oneofCondition(kind validateKind, bits int, param, value string) (string, error) {
+	options := strings.Fields(param)
+	if len(options) == 0 {
+		return "", fmt.Errorf("oneof rule requires at least one option")
+	}
+
+	var conds []string
+	for _, option := range options {
+		switch kind {
+		case validateString:
+			option = strconv.Quote(option)
+		case validateInt, validateUint, validateFloat:
+			if err := checkNumber(kind, bits, option); err != nil {
+				return "", fmt.Errorf("oneof rule %v", err)
+			}
+		default:
+			return "", fmt.Errorf("oneof rule is unsupported for the type of field")
+		}
+		conds = append(conds, fmt.Sprintf("%s != %s", value, option))
+	}
+	return strings.Join(conds, " && "), nil
+}
+
+// 规则参数需要能赋值给字段类型，否则生成的代码无法编译
+// e.g. int字段的min=1.5, int8字段的max=300
+func checkNumber(kind validateKind, bits int, param string) error {
+	switch kind {
+	case validateInt:
+		if _, err := strconv.ParseInt(param, 10, bits); err != nil {
+			return fmt.Errorf("requires an int%d integer, got %q", bits, param)
+		}
+	case validateUint:
+		if _, err := strconv.ParseUint(param, 10, bits); err != nil {
+			return fmt.Errorf("requires a uint%d non-negative integer, got %q", bits, param)
+		}
+	default:
+		if _, err := strconv.ParseFloat(param, bits); err != nil {
+			return fmt.Errorf("requires a float%d number, got %q", bits, param)
+		}
+	}
+	return nil
+}
+
+func invalidArgument(cond, msg, field string) string {
+	// msg作为格式化字符串使用
+	msg = strings.Replace(msg, "%", "%%", -1)
+	return fmt.Sprintf(
+		"if %s {\nreturn NewInvalidArgument(%q).WithDetail(\"field\", %q)\n}",
+		cond, msg, field,
+	)
+}
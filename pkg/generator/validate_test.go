@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"ezrpro.com/micro/kit/pkg/cst"
+)
+
+func TestNewFieldCheck(t *testing.T) {
+	const src = `package svc
+
+type Level int8
+
+type Email string
+
+type CreateRequest struct {
+	Small  int8
+	Port   uint16
+	Ratio  float32
+	Level  Level
+	Count  int
+	Mail   Email
+	PLevel *Level
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "svc.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := cst.NewConcreteSyntaxTree(fset, f)
+	if err := tree.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]cst.Field{}
+	for _, field := range tree.StructMap()["svc"]["CreateRequest"].Fields {
+		fields[field.Name] = field
+	}
+
+	tests := []struct {
+		field   string
+		tag     string
+		wantErr string // 为空时期望生成的代码包含want
+		want    string
+	}{
+		{field: "Small", tag: "max=127", want: "r.Small > 127"},
+		{field: "Small", tag: "max=300", wantErr: "max rule requires an int8 integer"},
+		{field: "Small", tag: "min=-129", wantErr: "min rule requires an int8 integer"},
+		{field: "Small", tag: "min=1.5", wantErr: "min rule requires an int8 integer"},
+		{field: "Port", tag: "oneof=80 443", want: "r.Port != 80 && r.Port != 443"},
+		{field: "Port", tag: "oneof=80 70000", wantErr: "oneof rule requires a uint16 non-negative integer"},
+		{field: "Port", tag: "min=-1", wantErr: "min rule requires a uint16 non-negative integer"},
+		{field: "Ratio", tag: "max=1e39", wantErr: "max rule requires a float32 number"},
+		{field: "Ratio", tag: "max=0.5", want: "r.Ratio > 0.5"},
+		{field: "Level", tag: "max=200", wantErr: "max rule requires an int8 integer"},
+		{field: "Level", tag: "max=100", want: "r.Level > 100"},
+		{field: "PLevel", tag: "oneof=1 256", wantErr: "oneof rule requires an int8 integer"},
+		{field: "Count", tag: "max=3000000000", want: "r.Count > 3000000000"},
+		{field: "Mail", tag: "email,max=10", want: "utf8.RuneCountInString(string(r.Mail)) > 10"},
+		{field: "Mail", tag: "email", want: "!validateEmail(string(r.Mail))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field+" "+tt.tag, func(t *testing.T) {
+			got, err := newFieldCheck(tree, fields[tt.field], tt.tag)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("newFieldCheck() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newFieldCheck() error = %v", err)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("newFieldCheck() =\n%s\nwant contains %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package generator
 
 import (
+	"bytes"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/template"
 )
 
 const (
@@ -75,6 +77,17 @@ func (w Writer) Close() error {
 	return w.Writer.Close()
 }
 
+// 模板执行成功后才输出到w，执行失败时w中没有任何内容
+// text/template在执行失败前可能已经输出了部分内容，合并已有文件时不能使用不完整的代码
+func ExecuteTemplate(t *template.Template, w io.Writer, data interface{}) error {
+	buff := &bytes.Buffer{}
+	if err := t.Execute(buff, data); err != nil {
+		return err
+	}
+	_, err := buff.WriteTo(w)
+	return err
+}
+
 type WriterOptions struct {
 	FileName   string
 	FileSuffix string
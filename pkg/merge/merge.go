@@ -0,0 +1,521 @@
+package merge
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+type Options struct {
+	// 已经存在的方法保持不变，只添加新的方法
+	// 用于生成后由用户填充实现的文件 e.g. basic_service.go, 接口的实现
+	keepFuncs bool
+	// 已经存在的结构体字段不删除，只添加新的字段
+	// 用于生成的结构体只是占位，字段由用户填充的文件 e.g. 没有语法树时生成的service.go
+	keepFields bool
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func WithKeepFuncs(keep bool) Option {
+	return func(o *Options) {
+		o.keepFuncs = keep
+	}
+}
+
+func WithKeepFields(keep bool) Option {
+	return func(o *Options) {
+		o.keepFields = keep
+	}
+}
+
+// 将新生成的代码合并到已经存在的文件中
+// 生成器生成的声明(方法、类型、变量及常量)覆盖已有的同名声明，新的声明追加到文件末尾
+// 结构体的字段及接口的方法按名称合并，保留已有字段的注释，生成的代码中已经没有的字段及方法被删除
+// 生成的类型上已经不再生成的方法被删除 e.g. 从服务接口中删除的方法对应的endpoint Set方法
+// 用户自己编写的其他声明及注释不做任何修改
+// 合并以文本替换的方式进行，输出的代码需要再经过格式化
+func Merge(filename string, existing, generated []byte, opts ...Option) ([]byte, error) {
+	options := newOptions(opts...)
+
+	fset := token.NewFileSet()
+	oldFile, err := parser.ParseFile(fset, filename, existing, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	newFile, err := parser.ParseFile(fset, filename+".generated", generated, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parse generated code of %s error: %s", filename, err)
+	}
+
+	m := &merger{
+		opts:     options,
+		fset:     fset,
+		oldSrc:   existing,
+		newSrc:   generated,
+		oldFile:  oldFile,
+		oldDecls: indexDecls(oldFile),
+	}
+	for _, decl := range newFile.Decls {
+		m.mergeDecl(decl)
+	}
+	if !options.keepFuncs {
+		m.removeDroppedMethods(newFile)
+	}
+	return m.output(), nil
+}
+
+// 删除生成的类型上已经不再生成的方法
+// 接收者不是生成的类型的方法以及普通函数由用户编写，保持不变
+func (m *merger) removeDroppedMethods(newFile *ast.File) {
+	var (
+		types = map[string]struct{}{}
+		funcs = map[string]struct{}{}
+	)
+	for _, decl := range newFile.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			funcs[funcKey(d)] = struct{}{}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if s, ok := spec.(*ast.TypeSpec); ok {
+					types[s.Name.Name] = struct{}{}
+				}
+			}
+		}
+	}
+
+	for _, decl := range m.oldFile.Decls {
+		d, ok := decl.(*ast.FuncDecl)
+		if !ok || d.Recv == nil {
+			continue
+		}
+		key := funcKey(d)
+		recv := strings.TrimSuffix(key, "."+d.Name.Name)
+		if _, found := types[recv]; !found || recv == key {
+			continue
+		}
+		if _, found := funcs[key]; !found {
+			m.remove(declStart(d), d.End())
+		}
+	}
+}
+
+// 对已有文件的一次文本替换
+type edit struct {
+	start, end int
+	text       string
+}
+
+type merger struct {
+	opts     Options
+	fset     *token.FileSet
+	oldSrc   []byte
+	newSrc   []byte
+	oldFile  *ast.File
+	oldDecls map[string]declRef
+	edits    []edit
+	imports  []string // 已有文件中缺少的import
+	appends  []string // 追加到文件末尾的声明
+}
+
+// 已有文件中的声明
+type declRef struct {
+	decl ast.Decl
+	spec ast.Spec // 类型、变量及常量声明中的spec
+}
+
+// 声明的唯一标识
+// 方法: Sum, 带接收者的方法: basicService.Sum
+// 类型: type.SumRequest, 变量及常量: var.ErrNotFound, const.PhoneTypeHome
+// 空白标识符的变量按声明的文本区分: var._ endpoint.Failer = SumResponse{}
+func declKeys(decl ast.Decl, spec ast.Spec, src []byte, fset *token.FileSet) []string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return []string{funcKey(d)}
+	case *ast.GenDecl:
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			return []string{"type." + s.Name.Name}
+		case *ast.ValueSpec:
+			var keys []string
+			for _, name := range s.Names {
+				if name.Name == "_" {
+					keys = append(keys, d.Tok.String()+"."+compact(text(fset, src, s.Pos(), s.End())))
+					continue
+				}
+				keys = append(keys, d.Tok.String()+"."+name.Name)
+			}
+			return keys
+		}
+	}
+	return nil
+}
+
+func funcKey(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return d.Name.Name
+	}
+	recv := d.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name + "." + d.Name.Name
+	}
+	return d.Name.Name
+}
+
+func indexDecls(f *ast.File) map[string]declRef {
+	decls := map[string]declRef{}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			decls[funcKey(d)] = declRef{decl: d}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					decls["type."+s.Name.Name] = declRef{decl: d, spec: s}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if name.Name != "_" {
+							decls[d.Tok.String()+"."+name.Name] = declRef{decl: d, spec: s}
+						}
+					}
+				}
+			}
+		}
+	}
+	return decls
+}
+
+func (m *merger) mergeDecl(decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		old, found := m.oldDecls[funcKey(d)]
+		if !found {
+			m.appends = append(m.appends, m.newText(declStart(d), d.End()))
+			return
+		}
+		if m.opts.keepFuncs {
+			return
+		}
+		m.replace(declStart(old.decl), old.decl.End(), m.newText(declStart(d), d.End()))
+	case *ast.GenDecl:
+		if d.Tok == token.IMPORT {
+			m.mergeImports(d)
+			return
+		}
+		group := m.findGroup(d)
+		for _, spec := range d.Specs {
+			m.mergeSpec(d, spec, group)
+		}
+	}
+}
+
+// 新生成的spec添加到已有的同一组声明中 e.g. var ( _ endpoint.Failer = MulResponse{} )
+func (m *merger) findGroup(d *ast.GenDecl) *ast.GenDecl {
+	if !d.Lparen.IsValid() {
+		return nil
+	}
+	for _, spec := range d.Specs {
+		for _, key := range declKeys(d, spec, m.newSrc, m.fset) {
+			old := m.findDecl(key)
+			if old == nil {
+				continue
+			}
+			if group, ok := old.decl.(*ast.GenDecl); ok && group.Lparen.IsValid() {
+				return group
+			}
+		}
+	}
+	return nil
+}
+
+func (m *merger) mergeSpec(d *ast.GenDecl, spec ast.Spec, group *ast.GenDecl) {
+	var old *declRef
+	for _, key := range declKeys(d, spec, m.newSrc, m.fset) {
+		if old = m.findDecl(key); old != nil {
+			break
+		}
+	}
+
+	if old == nil {
+		switch {
+		case group != nil:
+			m.insert(group.Rparen, m.newText(specStart(spec), spec.End())+"\n")
+		case d.Lparen.IsValid():
+			m.appends = append(m.appends, m.specText(d, spec))
+		default:
+			m.appends = append(m.appends, m.newText(declStart(d), d.End()))
+		}
+		return
+	}
+
+	// 空白标识符的变量完全一致，不需要处理
+	if old.spec == nil {
+		return
+	}
+
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		oldSpec := old.spec.(*ast.TypeSpec)
+		switch t := s.Type.(type) {
+		case *ast.StructType:
+			if oldType, ok := oldSpec.Type.(*ast.StructType); ok {
+				m.mergeFields(oldType.Fields, t.Fields, m.opts.keepFields)
+				return
+			}
+		case *ast.InterfaceType:
+			if oldType, ok := oldSpec.Type.(*ast.InterfaceType); ok {
+				m.mergeFields(oldType.Methods, t.Methods, false)
+				return
+			}
+		}
+		m.replace(oldSpec.Type.Pos(), oldSpec.Type.End(), m.newText(s.Type.Pos(), s.Type.End()))
+	case *ast.ValueSpec:
+		oldSpec := old.spec.(*ast.ValueSpec)
+		m.replace(oldSpec.Pos(), oldSpec.End(), m.newText(s.Pos(), s.End()))
+	}
+}
+
+// 按标识查找已有文件中的声明，空白标识符的变量返回所在的声明
+func (m *merger) findDecl(key string) *declRef {
+	if old, found := m.oldDecls[key]; found {
+		return &old
+	}
+	for _, decl := range m.oldFile.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range d.Specs {
+			for _, k := range declKeys(d, spec, m.oldSrc, m.fset) {
+				if k == key {
+					return &declRef{decl: d}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 按名称合并结构体的字段或者接口的方法
+// 同名的字段替换类型及tag，保留用户的注释；新的字段添加到末尾，已经不再生成的字段在keep为false时删除
+func (m *merger) mergeFields(oldList, newList *ast.FieldList, keep bool) {
+	newFields := map[string]struct{}{}
+	for _, field := range newList.List {
+		newFields[m.fieldKey(field, m.newSrc)] = struct{}{}
+	}
+
+	oldFields := map[string]*ast.Field{}
+	for _, field := range oldList.List {
+		key := m.fieldKey(field, m.oldSrc)
+		oldFields[key] = field
+		if _, found := newFields[key]; !found && !keep {
+			m.remove(fieldStart(field), fieldEnd(field))
+		}
+	}
+
+	var added []string
+	for _, field := range newList.List {
+		old, found := oldFields[m.fieldKey(field, m.newSrc)]
+		if !found {
+			added = append(added, m.newText(field.Pos(), field.End()))
+			continue
+		}
+		m.replace(old.Pos(), old.End(), m.newText(field.Pos(), field.End()))
+	}
+
+	if len(added) > 0 {
+		text := strings.Join(added, "\n") + "\n"
+		// 右括号不在单独一行时需要换行 e.g. struct{}
+		closing := m.fset.Position(oldList.Closing).Offset
+		if !bytes.HasSuffix(bytes.TrimRight(m.oldSrc[:closing], " \t"), []byte("\n")) {
+			text = "\n" + text
+		}
+		m.insert(oldList.Closing, text)
+	}
+}
+
+// 字段名，匿名字段使用类型 e.g. Name, *Options
+func (m *merger) fieldKey(field *ast.Field, src []byte) string {
+	if len(field.Names) == 0 {
+		return text(m.fset, src, field.Type.Pos(), field.Type.End())
+	}
+	var names []string
+	for _, name := range field.Names {
+		names = append(names, name.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (m *merger) mergeImports(d *ast.GenDecl) {
+	exists := map[string]struct{}{}
+	for _, imp := range m.oldFile.Imports {
+		exists[imp.Path.Value] = struct{}{}
+	}
+	for _, spec := range d.Specs {
+		imp := spec.(*ast.ImportSpec)
+		if _, found := exists[imp.Path.Value]; found {
+			continue
+		}
+		exists[imp.Path.Value] = struct{}{}
+		m.imports = append(m.imports, m.newText(imp.Pos(), imp.End()))
+	}
+}
+
+func (m *merger) replace(start, end token.Pos, text string) {
+	m.edits = append(m.edits, edit{
+		start: m.fset.Position(start).Offset,
+		end:   m.fset.Position(end).Offset,
+		text:  text,
+	})
+}
+
+func (m *merger) insert(pos token.Pos, text string) {
+	m.replace(pos, pos, text)
+}
+
+// 删除一段代码，独占的行整行删除，不留下空行
+func (m *merger) remove(start, end token.Pos) {
+	var (
+		from = m.fset.Position(start).Offset
+		to   = m.fset.Position(end).Offset
+	)
+	lineStart := bytes.LastIndexByte(m.oldSrc[:from], '\n') + 1
+	lineEnd := bytes.IndexByte(m.oldSrc[to:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(m.oldSrc) - to
+	}
+	if len(bytes.TrimSpace(m.oldSrc[lineStart:from])) == 0 &&
+		len(bytes.TrimSpace(m.oldSrc[to:to+lineEnd])) == 0 {
+		from, to = lineStart, to+lineEnd
+		if to < len(m.oldSrc) {
+			to++
+		}
+	}
+	m.edits = append(m.edits, edit{start: from, end: to})
+}
+
+func (m *merger) newText(start, end token.Pos) string {
+	return text(m.fset, m.newSrc, start, end)
+}
+
+func (m *merger) output() []byte {
+	if len(m.imports) > 0 {
+		// 缺少的import添加到最后一个import声明之后，没有import时添加到package之后
+		pos := m.oldFile.Name.End()
+		for _, decl := range m.oldFile.Decls {
+			if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+				pos = d.End()
+			}
+		}
+		m.insert(pos, "\n\nimport (\n"+strings.Join(m.imports, "\n")+"\n)\n")
+	}
+
+	// 从后向前替换，保证前面的偏移量不变
+	sort.SliceStable(m.edits, func(i, j int) bool {
+		return m.edits[i].start > m.edits[j].start
+	})
+	src := append([]byte{}, m.oldSrc...)
+	for _, e := range m.edits {
+		src = append(src[:e.start], append([]byte(e.text), src[e.end:]...)...)
+	}
+
+	buff := bytes.NewBuffer(src)
+	for _, decl := range m.appends {
+		buff.WriteString("\n\n")
+		buff.WriteString(decl)
+		buff.WriteString("\n")
+	}
+	return buff.Bytes()
+}
+
+// 包含注释的声明起始位置
+func declStart(decl ast.Decl) token.Pos {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	}
+	return decl.Pos()
+}
+
+// 包含注释的字段起始位置
+func fieldStart(field *ast.Field) token.Pos {
+	if field.Doc != nil {
+		return field.Doc.Pos()
+	}
+	return field.Pos()
+}
+
+// 包含行尾注释的字段结束位置
+func fieldEnd(field *ast.Field) token.Pos {
+	if field.Comment != nil {
+		return field.Comment.End()
+	}
+	return field.End()
+}
+
+// 括号中的spec单独作为一个声明追加，注释放在关键字之前 e.g. var ( A = 1 ) => var A = 1
+func (m *merger) specText(d *ast.GenDecl, spec ast.Spec) string {
+	var doc *ast.CommentGroup
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		doc = s.Doc
+	case *ast.ValueSpec:
+		doc = s.Doc
+	}
+
+	var text string
+	if doc != nil {
+		text = m.newText(doc.Pos(), doc.End()) + "\n"
+	}
+	return text + d.Tok.String() + " " + m.newText(spec.Pos(), spec.End())
+}
+
+// 包含注释的spec起始位置
+func specStart(spec ast.Spec) token.Pos {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		if s.Doc != nil {
+			return s.Doc.Pos()
+		}
+	case *ast.ValueSpec:
+		if s.Doc != nil {
+			return s.Doc.Pos()
+		}
+	}
+	return spec.Pos()
+}
+
+func text(fset *token.FileSet, src []byte, start, end token.Pos) string {
+	return string(src[fset.Position(start).Offset:fset.Position(end).Offset])
+}
+
+func compact(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
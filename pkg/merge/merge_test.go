@@ -0,0 +1,325 @@
+package merge
+
+import (
+	"go/format"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string
+		generated string
+		opts      []Option
+		want      string
+	}{
+		{
+			name: "replace generated func and keep user func",
+			existing: `package svc
+
+// Sum is generated.
+func Sum(a, b int) int {
+	return a - b
+}
+
+// helper is written by user.
+func helper() {}
+`,
+			generated: `package svc
+
+// Sum is generated.
+func Sum(a, b int) int {
+	return a + b
+}
+`,
+			want: `package svc
+
+// Sum is generated.
+func Sum(a, b int) int {
+	return a + b
+}
+
+// helper is written by user.
+func helper() {}
+`,
+		},
+		{
+			name: "keep funcs and append new ones",
+			existing: `package svc
+
+type basicService struct{}
+
+func (s basicService) Sum(a, b int) int {
+	return a + b // implemented by user
+}
+
+func (s basicService) Old() {}
+`,
+			generated: `package svc
+
+type basicService struct{}
+
+func (s basicService) Sum(a, b int) int {
+	panic("todo")
+}
+
+func (s basicService) Mul(a, b int) int {
+	panic("todo")
+}
+`,
+			opts: []Option{WithKeepFuncs(true)},
+			want: `package svc
+
+type basicService struct{}
+
+func (s basicService) Sum(a, b int) int {
+	return a + b // implemented by user
+}
+
+func (s basicService) Old() {}
+
+func (s basicService) Mul(a, b int) int {
+	panic("todo")
+}
+`,
+		},
+		{
+			name: "merge interface methods",
+			existing: `package svc
+
+type Service interface {
+	// Sum adds two numbers.
+	Sum(a, b int) int
+	Old()
+}
+`,
+			generated: `package svc
+
+type Service interface {
+	Sum(a, b int64) int64
+	Mul(a, b int64) int64
+}
+`,
+			want: `package svc
+
+type Service interface {
+	// Sum adds two numbers.
+	Sum(a, b int64) int64
+	Mul(a, b int64) int64
+}
+`,
+		},
+		{
+			name: "merge struct fields",
+			existing: `package svc
+
+type SumRequest struct {
+	// A is the first number.
+	A int ` + "`json:\"a\"`" + `
+	// B is dropped.
+	B int // trailing comment
+	C int
+}
+`,
+			generated: `package svc
+
+type SumRequest struct {
+	A int64 ` + "`json:\"a\"`" + `
+	C int
+	D string
+}
+`,
+			want: `package svc
+
+type SumRequest struct {
+	// A is the first number.
+	A int64 ` + "`json:\"a\"`" + `
+	C int
+	D string
+}
+`,
+		},
+		{
+			name: "keep fields filled by user",
+			existing: `package svc
+
+type Service interface {
+	Sum(a, b int) int
+}
+
+type SumRequest struct {
+	// A is the first number.
+	A int
+	B int // trailing comment
+}
+
+type SumResponse struct {
+	V       int
+	Code    int
+	Message string
+}
+`,
+			generated: `package svc
+
+type Service interface {
+	Sum(a, b int) int
+	Concat(a, b string) string
+}
+
+type SumRequest struct {
+}
+
+type SumResponse struct {
+	Code    int
+	Message string
+}
+
+type ConcatRequest struct {
+}
+`,
+			opts: []Option{WithKeepFields(true)},
+			want: `package svc
+
+type Service interface {
+	Sum(a, b int) int
+	Concat(a, b string) string
+}
+
+type SumRequest struct {
+	// A is the first number.
+	A int
+	B int // trailing comment
+}
+
+type SumResponse struct {
+	V       int
+	Code    int
+	Message string
+}
+
+type ConcatRequest struct {
+}
+`,
+		},
+		{
+			name: "remove dropped methods of generated types",
+			existing: `package svc
+
+type Set struct{}
+
+func (s Set) Sum() {}
+
+// Old is dropped from the service.
+func (s Set) Old() {}
+
+func (s *Set) Extra() {}
+
+type userType struct{}
+
+func (u userType) Old() {}
+`,
+			generated: `package svc
+
+type Set struct{}
+
+func (s Set) Sum() {}
+`,
+			want: `package svc
+
+type Set struct{}
+
+func (s Set) Sum() {}
+
+type userType struct{}
+
+func (u userType) Old() {}
+`,
+		},
+		{
+			name: "add imports and grouped specs",
+			existing: `package svc
+
+import "context"
+
+var (
+	_ = context.Background
+	A = 1
+)
+`,
+			generated: `package svc
+
+import (
+	"context"
+	"fmt"
+)
+
+var (
+	A = 2
+	B = fmt.Sprint()
+)
+`,
+			want: `package svc
+
+import "context"
+
+import (
+	"fmt"
+)
+
+var (
+	_ = context.Background
+	A = 2
+	B = fmt.Sprint()
+)
+`,
+		},
+		{
+			name: "append new declarations",
+			existing: `package svc
+
+type A struct{}
+`,
+			generated: `package svc
+
+type A struct{}
+
+// B is new.
+type B struct{}
+
+const C = 1
+`,
+			want: `package svc
+
+type A struct{}
+
+// B is new.
+type B struct{}
+
+const C = 1
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Merge("svc.go", []byte(tt.existing), []byte(tt.generated), tt.opts...)
+			if err != nil {
+				t.Fatalf("Merge() error = %v", err)
+			}
+			formatted, err := format.Source(got)
+			if err != nil {
+				t.Fatalf("format merged code error = %v\n%s", err, got)
+			}
+			if string(formatted) != tt.want {
+				t.Errorf("Merge() =\n%s\nwant:\n%s", formatted, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeInvalidGenerated(t *testing.T) {
+	_, err := Merge("svc.go", []byte("package svc\n"), []byte("package svc\nfunc Sum( {\n"))
+	if err == nil {
+		t.Fatal("Merge() expected error for incomplete generated code")
+	}
+}
@@ -16,11 +16,14 @@ import (
 func SetDefaults() {
 	viper.SetDefault("gk_service_path_format", "{{.Path}}/pkg/{{.ServiceName}}service")
 	viper.SetDefault("gk_protobuf_path_format", "{{.Path}}/pkg/{{.ServiceName}}pb")
+	viper.SetDefault("gk_thrift_path_format", "{{.Path}}/pkg/{{.ServiceName}}thrift")
 	viper.SetDefault("gk_endpoint_path_format", "{{.Path}}/pkg/{{.ServiceName}}endpoint")
 	viper.SetDefault("gk_transport_path_format", "{{.Path}}/pkg/{{.ServiceName}}transport")
 	viper.SetDefault("gk_server_path_format", "{{.Path}}/pkg/{{.ServiceName}}server")
 	viper.SetDefault("gk_client_path_format", "{{.Path}}/pkg/{{.ServiceName}}client")
 	viper.SetDefault("gk_impl_path_format", "{{.Path}}/pkg/{{.ServiceName}}impl")
+	viper.SetDefault("gk_mock_path_format", "{{.Path}}/pkg/{{.ServiceName}}mock")
+	viper.SetDefault("gk_test_path_format", "{{.Path}}/pkg/{{.ServiceName}}test")
 	viper.SetDefault("gk_service_suffix", "Service")
 	viper.SetDefault("gk_protobuf_service_suffix", "Server")
 	viper.SetDefault("gk_request_suffix", "Request")
@@ -136,6 +139,10 @@ func getProtobufPath(path, serviceName string) string {
 	return getPath("gk_protobuf_path_format", path, serviceName)
 }
 
+func getThriftPath(path, serviceName string) string {
+	return getPath("gk_thrift_path_format", path, serviceName)
+}
+
 func getEndpointPath(path, serviceName string) string {
 	return getPath("gk_endpoint_path_format", path, serviceName)
 }
@@ -156,6 +163,14 @@ func getImplPath(path, serviceName string) string {
 	return getPath("gk_impl_path_format", path, serviceName)
 }
 
+func getMockPath(path, serviceName string) string {
+	return getPath("gk_mock_path_format", path, serviceName)
+}
+
+func getTestPath(path, serviceName string) string {
+	return getPath("gk_test_path_format", path, serviceName)
+}
+
 func GetServiceSuffix() string {
 	return viper.GetString("gk_service_suffix")
 }
@@ -206,6 +221,12 @@ func SetProtobufPath(path string) {
 	viper.Set("gk_protobuf_path", path)
 }
 
+func GetThriftImportPath(svc string) string {
+	return getThriftPath(
+		strings.TrimLeft(GetPWDImportPath(), string(filepath.Separator)),
+		svc)
+}
+
 func GetEndpointImportPath(svc string) string {
 	return getEndpointPath(
 		strings.TrimLeft(GetPWDImportPath(), string(filepath.Separator)),
@@ -230,6 +251,12 @@ func GetClientImportPath(svc string) string {
 		svc)
 }
 
+func GetMockImportPath(svc string) string {
+	return getMockPath(
+		strings.TrimLeft(GetPWDImportPath(), string(filepath.Separator)),
+		svc)
+}
+
 func GetServiceFilePath(svc string) string {
 	return getServicePath(
 		GetPWD(),
@@ -242,6 +269,12 @@ func GetProtobufFilePath(svc string) string {
 		svc)
 }
 
+func GetThriftFilePath(svc string) string {
+	return getThriftPath(
+		GetPWD(),
+		svc)
+}
+
 func GetEndpointFilePath(svc string) string {
 	return getEndpointPath(
 		GetPWD(),
@@ -272,6 +305,18 @@ func GetImplFilePath(svc string) string {
 		svc)
 }
 
+func GetMockFilePath(svc string) string {
+	return getMockPath(
+		GetPWD(),
+		svc)
+}
+
+func GetTestFilePath(svc string) string {
+	return getTestPath(
+		GetPWD(),
+		svc)
+}
+
 // GetImportPathByFileAbsPath
 // 转换/Users/liuxingwang/go/src/ezrpro.com/micro/demo/pkg/addpb/addservice.pb.go
 // 成 ezrpro.com/micro/demo/pkg/addpb